@@ -0,0 +1,37 @@
+package fasttld
+
+// DomainAllowlist is a set of registered domains for checking if URLs belong to any of them.
+type DomainAllowlist struct {
+	f       *FastTLD
+	domains map[string]struct{}
+}
+
+// NewDomainAllowlist creates a *DomainAllowlist of registered domains (e.g. example.com or 例子.中国),
+// which are converted to lowercase punycode.
+//
+// Entries that are not registered domains (e.g. www.example.com, com or invalid hosts) are ignored,
+// so that listing a subdomain never allows its siblings.
+func (f *FastTLD) NewDomainAllowlist(domains []string) *DomainAllowlist {
+	a := &DomainAllowlist{f: f, domains: make(map[string]struct{}, len(domains))}
+	for _, domain := range domains {
+		res, err := f.Extract(URLParams{URL: domain, ConvertURLToPunyCode: true})
+		if err != nil || len(res.RegisteredDomain) == 0 || len(res.SubDomain) != 0 {
+			continue
+		}
+		a.domains[normalizeSuffix(res.RegisteredDomain)] = struct{}{}
+	}
+	return a
+}
+
+// Contains checks if the RegisteredDomain of url is in the allowlist, ignoring case.
+// Subdomains of listed domains are allowed (e.g. api.example.com is allowed if example.com is listed).
+//
+// Returns false if url is invalid or has no RegisteredDomain.
+func (a *DomainAllowlist) Contains(url string) bool {
+	res, err := a.f.Extract(URLParams{URL: url, ConvertURLToPunyCode: true})
+	if err != nil || len(res.RegisteredDomain) == 0 {
+		return false
+	}
+	_, ok := a.domains[normalizeSuffix(res.RegisteredDomain)]
+	return ok
+}
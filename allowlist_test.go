@@ -0,0 +1,42 @@
+package fasttld
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDomainAllowlist(t *testing.T) {
+	extractor, _ := New(SuffixListParams{
+		CacheFilePath: fmt.Sprintf("test%spublic_suffix_list.dat", string(os.PathSeparator)),
+	})
+	allowlist := extractor.NewDomainAllowlist([]string{
+		"example.com", "EXAMPLE.co.uk", "例子.中国", "www.example.org", "com", "127.0.0.1", "a_b.example.net",
+	})
+	for url, expected := range map[string]bool{
+		"https://example.com/path":        true,
+		"https://api.example.com:8443/v1": true,
+		"a.b.c.example.com":               true,
+		"https://www.Example.CO.UK":       true,
+		"http://www.例子.中国":                true,
+		"http://xn--fsqu00a.xn--fiqs8s":   true,
+		"example.com.":                    true,
+		"example。com":                     true,
+		"127.0.0.1:8080":                  true,
+		"https://example.net":             false,
+		"https://notexample.com":          false,
+		"https://example.com.evil.net":    false,
+		"https://www.example.org":         false,
+		"https://example.org":             false,
+		"https://com":                     false,
+		"localhost":                       false,
+		"":                                false,
+	} {
+		if output := allowlist.Contains(url); output != expected {
+			t.Errorf("%q | Output %t not equal to expected %t", url, output, expected)
+		}
+	}
+	if len(allowlist.domains) != 4 {
+		t.Errorf("Expected 4 domains in allowlist. Got %d.", len(allowlist.domains))
+	}
+}
@@ -1,93 +1,129 @@
 package fasttld
 
 import (
-	"fmt"
+	"strings"
 	"testing"
-
-	"github.com/fatih/color"
-	joeguotldextract "github.com/joeguo/tldextract"
-	tld "github.com/jpillora/go-tld"
-	mjd2021usatldextract "github.com/mjd2021usa/tldextract"
 )
 
-func BenchmarkComparison(b *testing.B) {
-	var benchmarkURLs = []string{
-		"https://iupac.org/iupac-announces-the-2021-top-ten-emerging-technologies-in-chemistry/",
-		"https://www.google.com/maps/dir/Parliament+Place,+Parliament+House+Of+Singapore,+" +
-			"Singapore/Parliament+St,+London,+UK/@25.2440033,33.6721455,4z/data=!3m1!4b1!4m14!4m13!1m5!1m1!1s0x31d" +
-			"a19a0abd4d71d:0xeda26636dc4ea1dc!2m2!1d103.8504863!2d1.2891543!1m5!1m1!1s0x487604c5aaa7da5b:0xf13a2" +
-			"197d7e7dd26!2m2!1d-0.1260826!2d51.5017061!3e4",
-		"https://a.b.c.d.e.f.g.h.i.j.k.l.m.n.oo.pp.qqq.rrrr.ssssss.tttttttt.uuuuuuuuuuu.vvvvvvvvvvvvvvv.wwwwwwwwwwwwwwwwwwwwww.xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx.yyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyy.zzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzz.cc",
-	}
-
-	benchmarks := []struct {
+func BenchmarkExtract(b *testing.B) {
+	var benchmarkURLs = []struct {
 		name string
+		url  string
 	}{
-		{"GoFastTld"},            // this module
-		{"JPilloraGoTld"},        // github.com/jpillora/go-tld
-		{"JoeGuoTldExtract"},     // github.com/joeguo/tldextract
-		{"Mjd2021USATldExtract"}, // github.com/mjd2021usa/tldextract
+		{"HostName", "https://user@www.maps.google.com.sg:8080/path/to/resource?query=1#fragment"},
+		{"IPv4", "https://127.0.0.1:8080/path/to/resource?query=1#fragment"},
+		{"IPv6", "https://[aBcD:ef01:2345:6789:aBcD:ef01:2345:6789]:8080/path/to/resource?query=1#fragment"},
+		{"International", "https://www.例子.敎育.hk/地图/A/b/C?编号=42"},
+		{"PathologicalSlashes", "http:" + strings.Repeat(`/\`, 5000) + "example.com"},
 	}
 
-	cache := "/tmp/tld.cache"
+	testPSLFilePath, _ := getTestPSLFilePath()
+	extractor, _ := New(SuffixListParams{
+		CacheFilePath:        testPSLFilePath,
+		IncludePrivateSuffix: false,
+	})
 
 	for _, benchmarkURL := range benchmarkURLs {
-		for _, bm := range benchmarks {
-			if bm.name == "GoFastTld" {
-				testPSLFilePath, _ := getTestPSLFilePath()
-				GoFastTld, _ := New(SuffixListParams{
-					CacheFilePath:        testPSLFilePath,
-					IncludePrivateSuffix: false,
-				})
-				b.Run(fmt.Sprint(bm.name), func(b *testing.B) {
-					for i := 0; i < b.N; i++ {
-						GoFastTld.Extract(URLParams{URL: benchmarkURL})
-					}
-				})
-			} else if bm.name == "JPilloraGoTld" {
-				// Provides the Port and Path subcomponents
-				// Cannot handle "+://google.com" and IP addresses
-				// Cannot handle urls without Scheme subcomponent
-				// Cannot handle trailing whitespace
-				b.Run(fmt.Sprint(bm.name), func(b *testing.B) {
-					for i := 0; i < b.N; i++ {
-						tld.Parse(benchmarkURL)
-					}
-				})
-			} else if bm.name == "JoeGuoTldExtract" {
-				JoeGuoTldExtract, _ := joeguotldextract.New(cache, false)
-				b.Run(fmt.Sprint(bm.name), func(b *testing.B) {
-					for i := 0; i < b.N; i++ {
-						JoeGuoTldExtract.Extract(benchmarkURL)
-					}
-				})
-
-			} else if bm.name == "Mjd2021USATldExtract" {
-				Mjd2021USATldExtract, _ := mjd2021usatldextract.New(cache, false)
-				b.Run(fmt.Sprint(bm.name), func(b *testing.B) {
-					for i := 0; i < b.N; i++ {
-						Mjd2021USATldExtract.Extract(benchmarkURL)
-					}
-				})
+		b.Run(benchmarkURL.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				extractor.Extract(URLParams{URL: benchmarkURL.url})
 			}
-		}
-		color.New().Println()
-		color.New(color.FgHiGreen, color.Bold).Print("Benchmarks completed for URL : ")
-		color.New(color.FgHiBlue).Println(benchmarkURL)
-		color.New(color.FgHiWhite).Println("=======")
+		})
 	}
 }
 
-/*
+func BenchmarkExtractHost(b *testing.B) {
+	const host = "www.maps.google.com.sg"
+
+	testPSLFilePath, _ := getTestPSLFilePath()
+	extractor, _ := New(SuffixListParams{
+		CacheFilePath:        testPSLFilePath,
+		IncludePrivateSuffix: false,
+	})
+
+	b.Run("Extract", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			extractor.Extract(URLParams{URL: host})
+		}
+	})
+	b.Run("ExtractHost", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			extractor.ExtractHost(host)
+		}
+	})
+}
+
+func BenchmarkRegisteredDomains(b *testing.B) {
+	hosts := []string{"www.maps.google.com.sg", "example.co.uk", "a.b.c.blogspot.com", "127.0.0.1", "localhost"}
+
+	testPSLFilePath, _ := getTestPSLFilePath()
+	extractor, _ := New(SuffixListParams{
+		CacheFilePath:        testPSLFilePath,
+		IncludePrivateSuffix: false,
+	})
 
-Omitted modules
+	b.Run("RegisteredDomains", func(b *testing.B) {
+		registeredDomains := make([]string, 0, len(hosts))
+		for i := 0; i < b.N; i++ {
+			registeredDomains = extractor.RegisteredDomains(registeredDomains[:0], hosts)
+		}
+	})
+	b.Run("ExtractHost", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			registeredDomains := make([]string, 0, len(hosts))
+			for _, host := range hosts {
+				res, _ := extractor.ExtractHost(host)
+				registeredDomains = append(registeredDomains, res.RegisteredDomain)
+			}
+		}
+	})
+}
 
-github.com/M507/tlde | Almost exactly the same as github.com/joeguo/tldextract
+func BenchmarkExtractPooled(b *testing.B) {
+	const url = "https://user@www.maps.google.com.sg:8080/path/to/resource?query=1#fragment"
 
-github.com/ImVexed/fasturl | Fast, but cannot extract eTLDs
+	testPSLFilePath, _ := getTestPSLFilePath()
+	extractor, _ := New(SuffixListParams{
+		CacheFilePath:        testPSLFilePath,
+		IncludePrivateSuffix: false,
+	})
 
-github.com/weppos/publicsuffix-go | Cannot handle full URLs with scheme (i.e. https:// ftp:// etc.)
+	b.Run("Extract", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			res, _ := extractor.Extract(URLParams{URL: url})
+			extractResultSink = &res
+		}
+	})
+	b.Run("ExtractPooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			res, release := extractor.ExtractPooled(URLParams{URL: url})
+			extractResultSink = res
+			release()
+		}
+	})
+}
+
+func BenchmarkExtractBytes(b *testing.B) {
+	url := []byte("https://user@www.maps.google.com.sg:8080/path/to/resource?query=1#fragment")
 
-github.com/forease/gotld | Does not extract subdomain properly and cannot handle ip addresses
+	testPSLFilePath, _ := getTestPSLFilePath()
+	extractor, _ := New(SuffixListParams{
+		CacheFilePath:        testPSLFilePath,
+		IncludePrivateSuffix: false,
+	})
 
-*/
+	b.Run("Extract", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			extractor.Extract(URLParams{URL: string(url)})
+		}
+	})
+	b.Run("ExtractBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			extractor.ExtractBytes(url, URLParams{})
+		}
+	})
+}
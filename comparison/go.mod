@@ -0,0 +1,26 @@
+module github.com/elliotwutingfeng/go-fasttld/comparison
+
+go 1.23
+
+require (
+	github.com/elliotwutingfeng/go-fasttld v0.0.0
+	github.com/fatih/color v1.18.0
+	github.com/joeguo/tldextract v0.0.0-20220507100122-d83daa6adef8
+	github.com/jpillora/go-tld v1.2.1
+	github.com/mjd2021usa/tldextract v0.9.2
+)
+
+require (
+	github.com/karlseguin/intset v1.0.3-0.20221130142345-37ee0d7df651 // indirect
+	github.com/klauspost/cpuid/v2 v2.2.9 // indirect
+	github.com/mattn/go-colorable v0.1.14 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/spf13/afero v1.12.0 // indirect
+	github.com/tidwall/hashmap v1.8.1 // indirect
+	github.com/zeebo/xxh3 v1.0.2 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
+
+replace github.com/elliotwutingfeng/go-fasttld => ../
@@ -7,11 +7,23 @@
 package fasttld
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/url"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
 
 	"github.com/spf13/afero"
 	"github.com/tidwall/hashmap"
@@ -22,14 +34,117 @@ const defaultPSLFolder string = "data"
 const defaultPSLFileName string = "public_suffix_list.dat"
 const largestPortNumber int = 65535
 const pslMaxAgeHours float64 = 72
+const defaultUpdateTimeout time.Duration = 30 * time.Second
+const defaultMaxSchemeLength int = 256
+const defaultMaxURLLength int = 65536
+
+// ErrIPHostRejected is returned by Extract if URLParams.RejectIPHosts = true
+// and the URL host is an IPv4 or IPv6 address.
+var ErrIPHostRejected = errors.New("IP address host rejected")
+
+// ErrSchemeTooLong is returned by Extract if the URL Scheme, including its slashes,
+// is longer than URLParams.MaxSchemeLength.
+var ErrSchemeTooLong = errors.New("scheme too long")
+
+// ErrInvalidScheme is returned by Extract if the URL begins with a Scheme followed by "://",
+// but the Scheme starts with a digit (e.g. 1b://example.com).
+var ErrInvalidScheme = errors.New("invalid scheme")
+
+// ErrInvalidPunycode is returned by Extract if a hostname label begins with "xn--" but is not valid punycode
+// (e.g. xn--0).
+var ErrInvalidPunycode = errors.New("invalid punycode label")
+
+// ErrLabelHyphen is returned by Extract if URLParams.EnforceLabelHyphenRules = true
+// and a hostname label violates the hyphen rules of RFC 5891.
+var ErrLabelHyphen = errors.New("label has invalid hyphens")
+
+// ErrEmptyDomain is returned by Extract if the hostname is empty or has no Domain (e.g. https:// or https://user@).
+// Components extracted before the hostname, such as Scheme and UserInfo, are still populated.
+var ErrEmptyDomain = errors.New("empty domain")
+
+// ErrSuffixOnly is returned by Extract if the hostname is a public suffix with no Domain (e.g. co.uk).
+// Suffix is still populated.
+var ErrSuffixOnly = errors.New("hostname is a public suffix")
+
+// ErrNumericHost is returned by Extract if URLParams.StrictNumericHost = true and the hostname
+// has only numeric labels but is not a valid IPv4 address (e.g. 1.2.3.4.5 or 127.0.0.256).
+var ErrNumericHost = errors.New("numeric hostname is not a valid IPv4 address")
+
+// ErrTooManySubDomainLabels is returned by Extract if SubDomain has more than URLParams.MaxSubDomainLabels labels.
+var ErrTooManySubDomainLabels = errors.New("too many subdomain labels")
+
+// ErrEmbeddedURLNotFound is returned by ExtractEmbedded if the URL has no query parameter with the given name,
+// or its value has invalid percent-encoding.
+var ErrEmbeddedURLNotFound = errors.New("embedded URL query parameter not found")
+
+// ErrURLTooLong is returned by Extract if the URL is longer than URLParams.MaxURLLength bytes.
+var ErrURLTooLong = errors.New("URL too long")
+
+// ErrMixedScript is returned by Extract if URLParams.RejectMixedScript = true
+// and a hostname label contains characters from more than one script.
+var ErrMixedScript = errors.New("label contains characters from more than one script")
+
+// ErrIncompletePSL is returned when constructing a suffix trie from a Public Suffix List file that is empty
+// or truncated (e.g. by an interrupted download), i.e. it is missing the BEGIN or END delimiters of the
+// ICANN or PRIVATE sections. New falls back to the hardcoded Public Suffix List if this happens.
+var ErrIncompletePSL = errors.New("public suffix list is empty or truncated")
+
+// ErrInvalidOrigin is returned by ExtractOrigin if the origin is not of the form scheme://host[:port].
+var ErrInvalidOrigin = errors.New("invalid origin")
+
+// ErrBadPercentEncoding is returned by Extract if URLParams.RejectBadPercentEncoding = true
+// and the hostname contains a "%" that is not followed by two hexadecimal digits.
+var ErrBadPercentEncoding = errors.New("invalid percent-encoding in hostname")
+
+// ErrCacheFileNotWritable is returned by Update if the Public Suffix List file at the cache file path
+// exists but is not a regular file that can be written to.
+var ErrCacheFileNotWritable = errors.New("cache file is not writable")
 
 // FastTLD provides the Extract() function, to extract
-// URLs using tldTrie generated from the
+// URLs using the suffix trie generated from the
 // Public Suffix List file at cacheFilePath.
+//
+// state holds the suffix trie, and is replaced as a whole whenever the suffix trie is updated,
+// so Extract never waits for Update.
 type FastTLD struct {
 	cacheFilePath        string
-	tldTrie              *trie
+	state                atomic.Pointer[suffixTrieState]
 	includePrivateSuffix bool
+	privateSuffixFilter  func(suffix string) bool
+	filesystem           afero.Fs
+	extractObserver      func(elapsed time.Duration)
+	updateTimeout        time.Duration
+	suffixSource         SuffixSource
+	cacheDir             string
+}
+
+// Extractor extracts URL components and updates its Public Suffix List, and is satisfied by *FastTLD.
+// Code that depends on Extractor instead of *FastTLD can be tested with a stub implementation.
+type Extractor interface {
+	Extract(e URLParams) (ExtractResult, error)
+	Update() error
+}
+
+var _ Extractor = (*FastTLD)(nil)
+
+// suffixTrieState is a suffix trie with the number of eTLD rules it was constructed from.
+// suffixes contains the sorted eTLD rules in tldTrie, and is only collected once by suffixesOnce.
+type suffixTrieState struct {
+	tldTrie      *trie
+	numRules     int
+	suffixesOnce sync.Once
+	suffixes     []string
+}
+
+// suffixTrie returns the current suffix trie.
+func (f *FastTLD) suffixTrie() *trie {
+	return f.state.Load().tldTrie
+}
+
+// setSuffixTrie replaces the suffix trie with tldTrie, constructed from numRules eTLD rules,
+// and resets cached suffix trie walks.
+func (f *FastTLD) setSuffixTrie(tldTrie *trie, numRules int) {
+	f.state.Store(&suffixTrieState{tldTrie: tldTrie, numRules: numRules})
 }
 
 // HostType indicates whether parsed URL
@@ -48,16 +163,143 @@ const (
 )
 
 // ExtractResult contains components extracted from URL.
+//
+// IsReverseDNS is true if Suffix is in-addr.arpa or ip6.arpa (e.g. 1.0.0.127.in-addr.arpa).
+//
+// ReverseDNSIP contains the IP address encoded by a reverse DNS pointer,
+// if the labels before Suffix form a complete IPv4 or IPv6 address.
+//
+// UnicodeSubDomain, UnicodeDomain and UnicodeSuffix contain the Unicode forms of
+// SubDomain, Domain and Suffix, and are only populated if URLParams.BothForms = true.
+//
+// Spans contains the byte offsets of SubDomain, Domain and Suffix, and is only populated
+// if URLParams.ReportSpans = true.
+//
+// PathStartIndex is the byte offset in URLParams.URL, before any trimming, where the "Path" (including any query
+// and fragment) begins, or -1 if there is no "Path". If URLParams.DecodeWholeURL = true, the offset is relative to
+// the decoded URL instead. PathStartIndex is only populated if URLParams.ReportOffsets = true.
+//
+// HadScheme is true if the URL began with a Scheme followed by a colon (e.g. http://example.com or mailto:),
+// and is false for schemeless (e.g. example.com) and scheme-relative (e.g. //example.com) URLs,
+// even if Scheme was set from URLParams.DefaultScheme.
+//
+// Input contains the original URLParams.URL before any trimming or decoding,
+// and is only populated if URLParams.RetainInput = true.
+//
+// Query and Fragment contain the query (without "?") and fragment (without "#") of the URL,
+// and are only populated if URLParams.SplitPath = true.
 type ExtractResult struct {
 	Scheme, UserInfo, SubDomain, Domain, Suffix, RegisteredDomain, Port, Path string
 	HostType                                                                  HostType
+
+	IsReverseDNS bool
+	ReverseDNSIP string
+
+	UnicodeSubDomain, UnicodeDomain, UnicodeSuffix string
+
+	Spans Spans
+
+	PathStartIndex int
+
+	HadScheme bool
+
+	Input string
+
+	Query, Fragment string
+}
+
+// Spans contains the start (inclusive) and end (exclusive) byte offsets of
+// SubDomain, Domain and Suffix in a hostname.
+//
+// Offsets are relative to the URL after leading whitespace, Scheme and UserInfo
+// (including its trailing "@") have been removed, i.e. the host begins at offset 0.
+// If URLParams.ConvertURLToPunyCode = true, offsets are relative to the punycode form of the host.
+//
+// If a component is absent, its start and end offsets are equal.
+type Spans struct {
+	SubDomainStart, SubDomainEnd int
+	DomainStart, DomainEnd       int
+	SuffixStart, SuffixEnd       int
+}
+
+// IDNAProfile specifies how internationalised hostnames are processed.
+type IDNAProfile int
+
+// IDNADefault, IDNATransitional and IDNANonTransitional specify how internationalised hostnames are processed.
+//
+// IDNADefault converts hostnames to punycode with IDNA2003 transitional processing
+// but validates hostnames without mapping.
+//
+// IDNATransitional converts and validates hostnames with IDNA2003 transitional processing
+// (e.g. ß is mapped to ss, and zero-width joiners are removed).
+//
+// IDNANonTransitional converts and validates hostnames with IDNA2008 non-transitional processing
+// (e.g. ß is kept and converted to punycode, and zero-width joiners are rejected).
+//
+// Unless the hostname is converted to punycode or mapped with URLParams.MapIDN, it is only validated
+// and is returned unchanged (e.g. faß.de -> Domain: faß with either profile).
+const (
+	IDNADefault IDNAProfile = iota
+	IDNATransitional
+	IDNANonTransitional
+)
+
+// punycodeProfile returns the IDNA profile used to convert hostnames to punycode.
+func (p IDNAProfile) punycodeProfile() *idna.Profile {
+	if p == IDNANonTransitional {
+		return idnaToPunyNonTransitional
+	}
+	return idnaToPuny
+}
+
+// validationProfile returns the IDNA profile used to validate punycode labels.
+func (p IDNAProfile) validationProfile() *idna.Profile {
+	if p == IDNADefault {
+		return idna.Punycode
+	}
+	return p.punycodeProfile()
+}
+
+// validate returns an error if host is invalid under p. Hostnames are validated by converting them to Unicode
+// with IDNADefault, and to punycode with the other profiles, so that their transitional or non-transitional
+// processing is applied.
+func (p IDNAProfile) validate(host string) error {
+	if p == IDNADefault {
+		_, err := idna.ToUnicode(host)
+		return err
+	}
+	_, err := p.punycodeProfile().ToASCII(host)
+	return err
 }
 
 // SuffixListParams contains parameters for specifying path to Public Suffix List file and
 // whether to extract private suffixes (e.g. blogspot.com).
+//
+// If CacheFilePath is not a valid Public Suffix List file, New uses public_suffix_list.dat in CacheDir
+// instead, downloading it if needed. CacheDir is created if it does not exist. If CacheDir is not set
+// or not writable, the temporary folder is used.
+//
+// UpdateTimeout limits the time taken by New to update an outdated Public Suffix List file.
+// If the update takes longer, New falls back to the outdated file if it is valid, otherwise the
+// hardcoded Public Suffix List. Defaults to 30 seconds if not set.
+//
+// If IncludePrivateSuffix = true and PrivateSuffixFilter is not nil, only private suffixes
+// for which PrivateSuffixFilter returns true are included (e.g. only blogspot.com but not fastly.net).
+// Suffixes with non-ASCII characters are passed to PrivateSuffixFilter in both punycode and Unicode forms.
+//
+// ExtractObserver, if not nil, is called with the time taken by each call to Extract (e.g. for latency metrics).
+//
+// SuffixSource, if not nil, provides the rules for the suffix trie, and CacheFilePath and UpdateTimeout are ignored.
+// No files are read and the Public Suffix List is not downloaded. The returned *FastTLD cannot be updated with Update.
+// If SuffixSource returns an error, New falls back to the hardcoded Public Suffix List.
 type SuffixListParams struct {
 	CacheFilePath        string
+	CacheDir             string
 	IncludePrivateSuffix bool
+	UpdateTimeout        time.Duration
+	PrivateSuffixFilter  func(suffix string) bool
+	ExtractObserver      func(elapsed time.Duration)
+	SuffixSource         SuffixSource
 }
 
 // URLParams specifies URL to extract components from.
@@ -65,24 +307,213 @@ type SuffixListParams struct {
 // If IgnoreSubDomains = true, do not extract SubDomain.
 //
 // If ConvertURLToPunyCode = true, convert non-ASCII characters like 世界 to punycode.
+//
+// If BothForms = true, convert non-ASCII characters to punycode as with ConvertURLToPunyCode,
+// and also return the Unicode forms of SubDomain, Domain and Suffix in
+// UnicodeSubDomain, UnicodeDomain and UnicodeSuffix. If the hostname is already ASCII
+// and contains no punycode labels, the Unicode forms are identical to the ASCII forms.
+//
+// If ParseOpaqueSchemes = true, URLs with a Scheme not followed by "//" (e.g. data:, javascript:, mailto:)
+// are not treated as hostnames. Scheme is set (e.g. "data:"), the rest of the URL is returned in Path,
+// and HostType is None. A host followed by a numeric port (e.g. localhost:8080) or UserInfo with a password
+// (e.g. user:pass@example.com) is still parsed as a hostname.
+//
+// Unless ParseOpaqueSchemes = true, the mailto: Scheme is separated from the email address that follows it,
+// which is parsed like a URL without a Scheme (e.g. mailto:user@example.com -> Scheme: mailto:, UserInfo: user,
+// Domain: example, Suffix: com). The email address must have a local part followed by "@", otherwise mailto
+// is parsed as a hostname (e.g. mailto:8080 -> Domain: mailto, Port: 8080).
+//
+// URLs with browser-internal Schemes (about:, brave:, chrome:, chrome-extension:, chrome-untrusted:, edge:,
+// moz-extension: and view-source:) are never treated as hostnames, regardless of ParseOpaqueSchemes, unless
+// they are followed by UserInfo (e.g. about:pass@example.com -> UserInfo: about:pass, Domain: example, Suffix: com).
+// Scheme is set including any slashes (e.g. "about:" or "chrome://"), the rest of the URL is returned in Path
+// (e.g. chrome://net-internals/#dns -> Path: net-internals/#dns), and HostType is None.
+//
+// If ReportSpans = true, populate ExtractResult.Spans for hostnames.
+//
+// If ColonNonNumericIsPath = true, treat a colon after the host followed by non-numeric runes
+// as the start of Path instead of a port (e.g. example.com:notaport -> Path: notaport).
+//
+// If RejectIPHosts = true, return ErrIPHostRejected if the URL host is an IPv4 or IPv6 address.
+//
+// If DefaultScheme is not empty and the URL has no Scheme, set Scheme to DefaultScheme (e.g. "https://").
+// This does not affect how the rest of the URL is parsed.
+//
+// TrimExtraChars contains extra characters (e.g. zero-width joiners or quotes) to trim from both ends
+// of the URL, in addition to whitespace.
+//
+// If RejectMixedScript = true, return ErrMixedScript if any hostname label contains characters from more than
+// one Unicode script (e.g. Latin "a" with Cyrillic "а"), which may indicate an IDN homograph attack.
+// Punycode labels are checked in their Unicode form. Japanese, Korean and Chinese labels mixing Han with
+// Hiragana/Katakana, Hangul or Bopomofo respectively are allowed, with or without Latin (e.g. abc日本).
+//
+// If DecodeWholeURL = true, percent-decode the entire URL (e.g. https%3A%2F%2Fexample.com) with url.QueryUnescape
+// before any other processing, including trimming. "+" is decoded as a space. Hostnames are percent-decoded
+// again during extraction, so hostnames that were percent-encoded twice will be fully decoded.
+//
+// If ReportOffsets = true, populate ExtractResult.PathStartIndex.
+//
+// If CanonicalizeIP = true, rewrite Domain and RegisteredDomain of IPv4 and IPv6 addresses to their canonical
+// textual form (e.g. [0:0:0:0:0:0:0:1] -> ::1). Domain and RegisteredDomain may then differ from the URL's literal bytes.
+//
+// If RetainInput = true, populate ExtractResult.Input with URL.
+//
+// MaxSchemeLength is the maximum length of Scheme, including its slashes (e.g. 8 for https://).
+// Return ErrSchemeTooLong if Scheme is longer. Defaults to 256 if not set.
+//
+// IDNAProfile specifies how internationalised hostnames are converted to punycode and validated.
+// Defaults to IDNADefault.
+//
+// Path, Query and Fragment are never percent-decoded unless DecodePath = true.
+// If DecodePath = true, percent-decode Path with url.PathUnescape (e.g. /a%20b -> /a b).
+// Query and Fragment are left unchanged, including when SplitPath = false (e.g. /a%20b?x=%20 -> /a b?x=%20).
+// Percent-encoded "?" and "#" (%3F and %23) in Path are kept encoded, and hostname processing is unaffected
+// by DecodePath.
+//
+// If RejectBadPercentEncoding = true, return ErrBadPercentEncoding if the hostname contains a "%" that is not
+// followed by two hexadecimal digits (e.g. %2 or %zz). Otherwise, such hostnames are rejected with the error
+// returned by url.QueryUnescape.
+//
+// LabelVisitor, if not nil, is called with each hostname label from right to left after the hostname has been
+// matched against the suffix trie. isSuffixPart is true for labels in Suffix. Labels are in the same form as
+// SubDomain, Domain and Suffix. LabelVisitor is not called for IP addresses or if Extract returns an error.
+//
+// If EnforceLabelHyphenRules = true, return ErrLabelHyphen if any hostname label starts or ends with "-"
+// (e.g. -foo or foo-), or has "--" in the third and fourth positions (e.g. ab--cd) but is not valid punycode
+// (e.g. xn--abc is allowed).
+//
+// If AllowObscureIPv4 = true, also accept IPv4 addresses in the forms accepted by web browsers, where each part
+// may be hexadecimal (0x prefix), octal (0 prefix) or decimal, and the last part fills the remaining bytes
+// (e.g. 0x7f.0.0.1, 0177.0.0.1, 127.1 and 2130706433). Domain and RegisteredDomain are rewritten
+// to dotted-decimal form (e.g. 127.0.0.1).
+//
+// By default, IPv4 addresses with leading zeros in octets (e.g. 192.168.01.1) are treated as hostnames.
+// If AllowIPv4LeadingZeros = true, accept them as IPv4 addresses, interpreting each octet as decimal.
+// Domain and RegisteredDomain are rewritten to dotted-decimal form without leading zeros (e.g. 192.168.1.1).
+// This takes precedence over the octal interpretation of AllowObscureIPv4.
+//
+// By default, hostnames with only numeric labels that are not valid IPv4 addresses are treated as hostnames
+// without a Suffix (e.g. 127.0.0.256 -> SubDomain: 127.0.0, Domain: 256 and 1.2.3.4.5 -> SubDomain: 1.2.3.4, Domain: 5).
+// If StrictNumericHost = true, return ErrNumericHost for such hostnames instead.
+//
+// MaxSubDomainLabels is the maximum number of labels in SubDomain, counting all label separators
+// (e.g. 2 for a.b.example.com). Return ErrTooManySubDomainLabels if SubDomain has more labels.
+// SubDomain labels are counted even if IgnoreSubDomains = true. No limit is applied if not set.
+//
+// If NormalizeScheme = true, convert the letters of Scheme to lowercase, keeping digits, "+", "-", "." and
+// slashes unchanged (e.g. GIT+SSH:// -> git+ssh://). This also applies to DefaultScheme.
+//
+// If NormalizeBackslashes = true, convert backslashes to slashes in Scheme and in the "Path" before any query
+// or fragment, as web browsers do (e.g. http:\\example.com\a\b?c\d -> Scheme: http://, Path: /a/b?c\d).
+// Backslashes are kept unchanged by default.
+//
+// By default, if ConvertURLToPunyCode = false and BothForms = false, the hostname is only validated and is returned
+// unchanged (e.g. ｅｘａｍｐｌｅ.com -> Domain: ｅｘａｍｐｌｅ), and some characters disallowed by IDNA (e.g. U+0379)
+// are accepted. If MapIDN = true, map the hostname with IDNAProfile as when converting to punycode, but keep it in
+// Unicode form (e.g. ｅｘａｍｐｌｅ.COM -> Domain: example, Suffix: com), and return an error if it has characters
+// disallowed by IDNA. Label separators are replaced with "." and spans are relative to the mapped hostname.
+//
+// If FillDefaultPort = true and the URL has no Port, set Port to the default port of Scheme as returned by
+// DefaultPort (e.g. https://example.com -> Port: 443). This also applies to DefaultScheme. Port is left empty
+// for IP addresses and Schemes without a known default port (e.g. foo://example.com).
+//
+// If CanonicalRegisteredDomain = true, match hostname labels against the suffix trie in lowercase punycode form,
+// and convert Domain, Suffix and RegisteredDomain of hostnames to lowercase punycode with "." as the label
+// separator, as returned by FastTLD.CanonicalRegisteredDomain, even if ConvertURLToPunyCode = false
+// (e.g. www.例子.中国 -> SubDomain: www, Domain: xn--fsqu00a, Suffix: xn--fiqs8s, and WWW.EXAMPLE.COM ->
+// SubDomain: WWW, Domain: example, Suffix: com). SubDomain, Path and other components are unchanged,
+// and spans are relative to the unconverted hostname. Hostnames without a RegisteredDomain (e.g. localhost)
+// and IP addresses are unchanged.
+//
+// MaxURLLength is the maximum length of URL in bytes, which is checked before any other processing.
+// Return ErrURLTooLong if URL is longer. Defaults to 65536 if not set.
+//
+// If BestEffort = true and Extract returns the "invalid characters in hostname" error, Domain, Suffix and
+// RegisteredDomain are still populated if the hostname has a Suffix and only SubDomain has invalid characters
+// (e.g. a_b.example.com -> Domain: example, Suffix: com). SubDomain is always empty in this case.
+//
+// If CollapseSeparators = true, collapse runs of consecutive label separators between labels
+// into a single "." (e.g. a..b.example.com -> a.b.example.com) instead of returning an error.
+// Spans are relative to the collapsed host.
+//
+// If SplitPath = true, split the "Path" into Path, Query and Fragment
+// (e.g. /a?b=1#c -> Path: /a, Query: b=1, Fragment: c).
+//
+// If FragmentBeforeQuery = true and SplitPath = true, a "?" after "#" starts Query instead of being part of Fragment,
+// for URLs that place the fragment before the query (e.g. /a#c?b=1 -> Path: /a, Query: b=1, Fragment: c).
 type URLParams struct {
-	URL                  string
-	IgnoreSubDomains     bool
-	ConvertURLToPunyCode bool
+	URL                       string
+	IgnoreSubDomains          bool
+	ConvertURLToPunyCode      bool
+	BothForms                 bool
+	ParseOpaqueSchemes        bool
+	ReportSpans               bool
+	ColonNonNumericIsPath     bool
+	RejectIPHosts             bool
+	DefaultScheme             string
+	TrimExtraChars            string
+	RejectMixedScript         bool
+	DecodeWholeURL            bool
+	CanonicalizeIP            bool
+	RetainInput               bool
+	MaxSchemeLength           int
+	IDNAProfile               IDNAProfile
+	SplitPath                 bool
+	FragmentBeforeQuery       bool
+	CollapseSeparators        bool
+	DecodePath                bool
+	RejectBadPercentEncoding  bool
+	LabelVisitor              func(label string, isSuffixPart bool)
+	BestEffort                bool
+	MaxURLLength              int
+	EnforceLabelHyphenRules   bool
+	AllowObscureIPv4          bool
+	AllowIPv4LeadingZeros     bool
+	StrictNumericHost         bool
+	MaxSubDomainLabels        int
+	NormalizeScheme           bool
+	NormalizeBackslashes      bool
+	ReportOffsets             bool
+	MapIDN                    bool
+	FillDefaultPort           bool
+	CanonicalRegisteredDomain bool
+}
+
+// visitLabels calls visitor for each label in host from right to left.
+// isSuffixPart is true for labels starting at or after suffixStart if hasSuffix is true.
+func visitLabels(host string, suffixStart int, hasSuffix bool, visitor func(label string, isSuffixPart bool)) {
+	labelEndIdx := len(host)
+	for labelEndIdx >= 0 {
+		sepIdx := lastIndexAny(host[0:labelEndIdx], labelSeparatorsRuneSet)
+		labelStartIdx := 0
+		if sepIdx != -1 {
+			labelStartIdx = sepIdx + sepSize(host[sepIdx])
+		}
+		visitor(host[labelStartIdx:labelEndIdx], hasSuffix && labelStartIdx >= suffixStart)
+		if sepIdx == -1 {
+			break
+		}
+		labelEndIdx = sepIdx
+	}
 }
 
 // trie is a node of the compressed trie
 // used to store Public Suffix List eTLDs.
+//
+// private is true if the node is the last label of a PRIVATE domain suffix (e.g. blogspot.com).
 type trie struct {
 	matches hashmap.Map[string, *trie]
 	end     bool
+	private bool
 }
 
 // nestedDict stores a slice of keys in the trie, by traversing the trie using the keys as a "path",
 // creating new tries for keys that do not exist yet.
 //
 // If a new path overlaps an existing path, flag the previous path's trie node as end = true.
-func nestedDict(dic *trie, keys []string) {
+//
+// Returns the trie node of the last key.
+func nestedDict(dic *trie, keys []string) *trie {
 	for _, key := range keys {
 		if _, ok := dic.matches.Get(key); !ok {
 			// key doesn't exist; add new node
@@ -93,60 +524,349 @@ func nestedDict(dic *trie, keys []string) {
 	}
 	// set last node to end = true
 	dic.end = true
+	return dic
 }
 
 // trieConstruct constructs a compressed trie to store Public Suffix List eTLDs split at "." in reverse-order.
 //
 // For example: "us.gov.pl" will be stored in the order {"pl", "gov", "us"}.
-func trieConstruct(includePrivateSuffix bool, cacheFilePath string) (*trie, error) {
-	var m hashmap.Map[string, *trie]
-	tldTrie := &trie{matches: m}
-
-	var suffixLists suffixes
-	var err error
+//
+// If privateSuffixFilter is not nil, only private suffixes for which it returns true are included.
+func trieConstruct(includePrivateSuffix bool, privateSuffixFilter func(suffix string) bool, cacheFilePath string) (*trie, int, error) {
 	if cacheFilePath != "" {
-		suffixLists, err = getPublicSuffixList(cacheFilePath)
-	} else {
-		suffixLists, err = getHardcodedPublicSuffixList()
+		file, err := os.Open(cacheFilePath)
+		if err != nil {
+			log.Println(err)
+			var m hashmap.Map[string, *trie]
+			return &trie{matches: m}, 0, err
+		}
+		defer file.Close()
+		return trieConstructFromReader(includePrivateSuffix, privateSuffixFilter, file)
 	}
 
+	return trieConstructFromSource(includePrivateSuffix, privateSuffixFilter, hardcodedSuffixSource{})
+}
+
+// trieConstructFromSource constructs a compressed trie like trieConstruct, using rules from source.
+func trieConstructFromSource(includePrivateSuffix bool, privateSuffixFilter func(suffix string) bool, source SuffixSource) (*trie, int, error) {
+	var m hashmap.Map[string, *trie]
+	tldTrie := &trie{matches: m}
+
+	publicSuffixes, privateSuffixes, err := source.Suffixes()
 	if err != nil {
 		log.Println(err)
-		return tldTrie, err
+		return tldTrie, 0, err
 	}
 
-	var suffixList []string
+	numRules := insertSuffixes(tldTrie, publicSuffixes, false)
 	if includePrivateSuffix {
-		suffixList = suffixLists.allSuffixes
-	} else {
-		suffixList = suffixLists.publicSuffixes
+		numRules += insertSuffixes(tldTrie, filterSuffixes(privateSuffixes, privateSuffixFilter), true)
+	}
+	markWildcardNodes(tldTrie)
+
+	return tldTrie, numRules, nil
+}
+
+// trieConstructFromReader constructs a compressed trie like trieConstruct, but reads the
+// Public Suffix List from r one line at a time, without loading the entire list into memory.
+func trieConstructFromReader(includePrivateSuffix bool, privateSuffixFilter func(suffix string) bool, r io.Reader) (*trie, int, error) {
+	var m hashmap.Map[string, *trie]
+	tldTrie := &trie{matches: m}
+
+	scanner := bufio.NewScanner(r)
+	// Comment lines in the Public Suffix List have no length limit
+	scanner.Buffer(nil, math.MaxInt)
+	var isPrivateSuffix bool
+	var delimitersFound, numRules int
+	for scanner.Scan() {
+		line := scanner.Text()
+		if delimitersFound < len(pslDelimiters) && strings.TrimSpace(line) == pslDelimiters[delimitersFound] {
+			delimitersFound++
+		}
+		var psl suffixes
+		psl, isPrivateSuffix = processLine(line, psl, isPrivateSuffix)
+		numRules += insertSuffixes(tldTrie, psl.publicSuffixes, false)
+		if includePrivateSuffix {
+			numRules += insertSuffixes(tldTrie, filterSuffixes(psl.privateSuffixes, privateSuffixFilter), true)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Println(err)
+		return tldTrie, numRules, err
+	}
+	if delimitersFound != len(pslDelimiters) {
+		err := fmt.Errorf("%w: missing %q", ErrIncompletePSL, pslDelimiters[delimitersFound])
+		log.Println(err)
+		return &trie{matches: m}, 0, err
 	}
+	markWildcardNodes(tldTrie)
 
+	return tldTrie, numRules, nil
+}
+
+// filterSuffixes returns suffixes in suffixList for which filter returns true.
+// If filter is nil, suffixList is returned unchanged.
+func filterSuffixes(suffixList []string, filter func(suffix string) bool) []string {
+	if filter == nil {
+		return suffixList
+	}
+	var filtered []string
 	for _, suffix := range suffixList {
+		if filter(suffix) {
+			filtered = append(filtered, suffix)
+		}
+	}
+	return filtered
+}
+
+// insertSuffixes stores each suffix in suffixList in the trie, split at "." in reverse-order.
+//
+// If isPrivate = true, the last node of each suffix is flagged as private = true.
+func insertSuffixes(tldTrie *trie, suffixList []string, isPrivate bool) int {
+	var numRules int
+	for _, suffix := range suffixList {
+		if isASCII(suffix) {
+			// rules with non-ASCII characters are listed in both punycode and Unicode forms, count them once
+			numRules++
+		}
 		sp := strings.Split(suffix, ".")
 		reverse(sp)
-		nestedDict(tldTrie, sp)
+		if node := nestedDict(tldTrie, sp); isPrivate {
+			node.private = true
+		}
+	}
+	return numRules
+}
+
+// suffixNode returns the trie node matching the last label of suffix, following
+// wildcard rules (e.g. *.ck) if necessary. Returns false if suffix is not in the trie.
+func (f *FastTLD) suffixNode(suffix string) (*trie, bool) {
+	if len(suffix) == 0 {
+		return nil, false
+	}
+	node := f.suffixTrie()
+	sepIdx := len(suffix)
+	for sepIdx != -1 {
+		previousSepIdx := sepIdx
+		var label string
+		sepIdx = lastIndexAny(suffix[0:sepIdx], labelSeparatorsRuneSet)
+		if sepIdx != -1 {
+			label = suffix[sepIdx+sepSize(suffix[sepIdx]) : previousSepIdx]
+		} else {
+			label = suffix[0:previousSepIdx]
+		}
+		label, _ = url.QueryUnescape(label)
+		if label == "*" || strings.HasPrefix(label, "!") {
+			// wildcard and exception rules are not suffixes
+			return nil, false
+		}
+		if val, ok := node.matches.Get(label); ok {
+			node = val
+		} else if val, ok := node.matches.Get("*"); ok {
+			if _, ok := node.matches.Get("!" + label); ok {
+				return nil, false
+			}
+			node = val
+		} else {
+			return nil, false
+		}
 	}
+	return node, node.end
+}
 
+// markWildcardNodes flags top level trie nodes with a wildcard rule (e.g. *.ck) as end = true.
+func markWildcardNodes(tldTrie *trie) {
 	tldTrie.matches.Scan(func(key string, value *trie) bool {
 		if _, ok := value.matches.Get("*"); ok {
 			value.end = true
 		}
 		return true
 	})
+}
 
-	return tldTrie, nil
+// scheme returns s, with its letters converted to lowercase if NormalizeScheme = true,
+// and its backslashes converted to slashes if NormalizeBackslashes = true.
+func (e URLParams) scheme(s string) string {
+	if e.NormalizeScheme {
+		s = strings.ToLower(s)
+	}
+	if e.NormalizeBackslashes {
+		s = strings.ReplaceAll(s, `\`, "/")
+	}
+	return s
 }
 
 // Extract components from a given `url`.
+//
+// Leading UTF-8 byte order marks (U+FEFF) are removed from `url` before parsing.
+// `url` must be UTF-8 encoded; inputs in other encodings (e.g. UTF-16) must be converted by the caller.
+//
+// Trailing label separators of fully qualified hostnames (e.g. example.com. or 例子.中国。) are ignored when
+// matching Suffix, and are excluded from Suffix and RegisteredDomain.
 func (f *FastTLD) Extract(e URLParams) (ExtractResult, error) {
+	if f.extractObserver == nil {
+		return f.extract(e)
+	}
+	start := time.Now()
+	urlParts, err := f.extract(e)
+	f.extractObserver(time.Since(start))
+	return urlParts, err
+}
+
+// pooledExtractResult is an ExtractResult held by extractResultPool, with a release function
+// that is created once so that ExtractPooled does not allocate a new closure on every call.
+type pooledExtractResult struct {
+	res     ExtractResult
+	release func()
+}
+
+// extractResultPool holds *pooledExtractResult values for reuse by ExtractPooled.
+var extractResultPool sync.Pool
+
+func init() {
+	extractResultPool.New = func() any {
+		p := new(pooledExtractResult)
+		p.release = func() {
+			// clear fields so that the pool does not keep URLParams.URL in memory
+			p.res = ExtractResult{}
+			extractResultPool.Put(p)
+		}
+		return p
+	}
+}
+
+// ExtractPooled extracts components from a given `url` like Extract, into an *ExtractResult taken from a pool.
+// This avoids allocating a new ExtractResult on the heap for every URL when results must be passed by pointer
+// (e.g. to other goroutines in high-throughput servers). Returns a nil *ExtractResult if Extract returns an error;
+// use Extract if the error is needed.
+//
+// release returns the *ExtractResult to the pool, and must be called exactly once after the result is no longer
+// used. The *ExtractResult must not be used after release is called, as it may be handed out again.
+//
+// Fields of the *ExtractResult are substrings of URLParams.URL that share its memory. They keep URLParams.URL
+// in memory until release is called, so copy any field (e.g. with strings.Clone) that must outlive release.
+func (f *FastTLD) ExtractPooled(e URLParams) (res *ExtractResult, release func()) {
+	urlParts, err := f.Extract(e)
+	if err != nil {
+		return nil, func() {}
+	}
+	p := extractResultPool.Get().(*pooledExtractResult)
+	p.res = urlParts
+	return &p.res, p.release
+}
+
+// ExtractBytes extracts components from `url` like Extract, without converting `url` to a string first
+// (e.g. when parsing URLs from a []byte buffer). opts.URL is ignored.
+//
+// Only the extracted components are copied into new strings, so the result and error do not share memory with
+// `url`, and `url` may be modified or reused after ExtractBytes returns. However, labels passed to
+// opts.LabelVisitor are substrings of `url`, and are only valid until `url` is modified or reused.
+func (f *FastTLD) ExtractBytes(url []byte, opts URLParams) (ExtractResult, error) {
+	// url is only read during extraction, and must not be modified by Extract
+	opts.URL = unsafe.String(unsafe.SliceData(url), len(url))
+	urlParts, err := f.Extract(opts)
+	return cloneExtractResult(urlParts), cloneExtractError(err)
+}
+
+// cloneExtractError returns err with any substring of the URL it holds copied, so that it does not share memory
+// with the URL it was extracted from. Other errors from Extract are formatted into new strings already.
+func cloneExtractError(err error) error {
+	if escapeErr, ok := err.(url.EscapeError); ok {
+		return url.EscapeError(strings.Clone(string(escapeErr)))
+	}
+	return err
+}
+
+// cloneExtractResult returns urlParts with every string field copied, so that it does not share memory
+// with the URL it was extracted from.
+func cloneExtractResult(urlParts ExtractResult) ExtractResult {
+	for _, s := range []*string{&urlParts.Scheme, &urlParts.UserInfo, &urlParts.SubDomain, &urlParts.Domain,
+		&urlParts.Suffix, &urlParts.RegisteredDomain, &urlParts.Port, &urlParts.Path, &urlParts.ReverseDNSIP,
+		&urlParts.UnicodeSubDomain, &urlParts.UnicodeDomain, &urlParts.UnicodeSuffix,
+		&urlParts.Input, &urlParts.Query, &urlParts.Fragment} {
+		*s = strings.Clone(*s)
+	}
+	return urlParts
+}
+
+// extract extracts components from a given `url`, without calling the extract observer.
+func (f *FastTLD) extract(e URLParams) (ExtractResult, error) {
+	maxURLLength := e.MaxURLLength
+	if maxURLLength <= 0 {
+		maxURLLength = defaultMaxURLLength
+	}
+	if len(e.URL) > maxURLLength {
+		return ExtractResult{}, ErrURLTooLong
+	}
+
 	urlParts := ExtractResult{}
+	if e.RetainInput {
+		urlParts.Input = e.URL
+	}
+
+	rawURL := e.URL
+	if e.DecodeWholeURL {
+		decodedURL, err := url.QueryUnescape(rawURL)
+		if err != nil {
+			return urlParts, err
+		}
+		rawURL = decodedURL
+	}
 
 	// Extract URL scheme
-	netloc := fastTrim(e.URL, whitespaceRuneSet, trimBoth)
-	if schemeEndIndex := getSchemeEndIndex(netloc); schemeEndIndex != -1 {
-		urlParts.Scheme = netloc[0:schemeEndIndex]
+	trimRuneSet := getTrimRuneSet(e.TrimExtraChars)
+	netloc := fastTrim(stripByteOrderMarks(rawURL), trimRuneSet, trimBoth)
+	trimmedURL := netloc
+	// pathOffset returns the offset of path in rawURL. path must be a suffix of trimmedURL.
+	pathOffset := func(path string) int {
+		trimOffset := len(rawURL) - len(fastTrim(stripByteOrderMarks(rawURL), trimRuneSet, trimLeft))
+		return trimOffset + len(trimmedURL) - len(path)
+	}
+	if e.ReportOffsets {
+		urlParts.PathStartIndex = -1
+	}
+	if schemeEndIndex := getBrowserInternalSchemeEndIndex(netloc); schemeEndIndex != -1 {
+		// browser-internal page (e.g. about:blank or chrome://settings); skip host extraction
+		urlParts.Scheme = e.scheme(netloc[0:schemeEndIndex])
+		urlParts.HadScheme = true
+		urlParts.Path = netloc[schemeEndIndex:]
+		if e.ReportOffsets && len(urlParts.Path) != 0 {
+			urlParts.PathStartIndex = pathOffset(urlParts.Path)
+		}
+		return urlParts, nil
+	}
+	if e.ParseOpaqueSchemes {
+		if schemeEndIndex := getOpaqueSchemeEndIndex(netloc); schemeEndIndex != -1 {
+			// no authority component; skip host extraction
+			urlParts.Scheme = e.scheme(netloc[0:schemeEndIndex])
+			urlParts.HadScheme = true
+			urlParts.Path = netloc[schemeEndIndex:]
+			if e.ReportOffsets && len(urlParts.Path) != 0 {
+				urlParts.PathStartIndex = pathOffset(urlParts.Path)
+			}
+			return urlParts, nil
+		}
+	}
+	maxSchemeLength := e.MaxSchemeLength
+	if maxSchemeLength <= 0 {
+		maxSchemeLength = defaultMaxSchemeLength
+	}
+	if hasMailtoScheme(netloc) && hasUserInfo(netloc[len(mailtoScheme):]) {
+		// email address; Scheme is followed by the local part in UserInfo instead of slashes
+		urlParts.Scheme = e.scheme(netloc[0:len(mailtoScheme)])
+		urlParts.HadScheme = true
+		netloc = netloc[len(mailtoScheme):]
+	} else if schemeEndIndex, tooLong := getSchemeEndIndex(netloc, maxSchemeLength); tooLong {
+		return urlParts, ErrSchemeTooLong
+	} else if schemeEndIndex != -1 {
+		urlParts.Scheme = e.scheme(netloc[0:schemeEndIndex])
+		// scheme-relative URLs (e.g. //example.com) have no colon
+		urlParts.HadScheme = strings.IndexByte(urlParts.Scheme, ':') != -1
 		netloc = netloc[schemeEndIndex:]
+	} else if scheme, ok := invalidScheme(netloc); ok {
+		return urlParts, fmt.Errorf("%w: %q", ErrInvalidScheme, scheme)
+	} else {
+		urlParts.Scheme = e.scheme(e.DefaultScheme)
 	}
 
 	// Extract URL userinfo
@@ -242,6 +962,9 @@ func (f *FastTLD) Extract(e URLParams) (ExtractResult, error) {
 			}
 			if port, err := strconv.Atoi(maybePort); err == nil && 0 <= port && port <= largestPortNumber {
 				urlParts.Port = maybePort
+			} else if e.ColonNonNumericIsPath && len(maybePort) != 0 && !isNumeric(maybePort) {
+				// colon is not followed by a port; treat runes after colon as Path
+				pathStartIndex = 1
 			} else {
 				return urlParts, errors.New("invalid port")
 			}
@@ -251,31 +974,152 @@ func (f *FastTLD) Extract(e URLParams) (ExtractResult, error) {
 			// See https://stackoverflow.com/questions/47543432/what-do-we-call-the-combined-path-query-and-fragment-in-a-uri
 			// For simplicity, we shall call this the "Path".
 			urlParts.Path = afterHost[pathStartIndex:]
+			if e.ReportOffsets {
+				urlParts.PathStartIndex = pathOffset(urlParts.Path)
+			}
+			if e.NormalizeBackslashes {
+				urlParts.Path = normalizeBackslashes(urlParts.Path)
+			}
+			if e.SplitPath {
+				urlParts.Path, urlParts.Query, urlParts.Fragment = splitPath(urlParts.Path, e.FragmentBeforeQuery)
+			}
+			if e.DecodePath {
+				decodedPath, err := decodePath(urlParts.Path)
+				if err != nil {
+					return urlParts, err
+				}
+				urlParts.Path = decodedPath
+			}
 		}
 	}
 
 	if urlParts.HostType == IPv6 {
+		if e.RejectIPHosts {
+			return ExtractResult{}, ErrIPHostRejected
+		}
+		if e.CanonicalizeIP {
+			urlParts.Domain = canonicalIP(urlParts.Domain)
+			urlParts.RegisteredDomain = urlParts.Domain
+		}
 		return urlParts, nil
 	}
 
+	urlParts, err := f.extractHostName(f.suffixTrie(), urlParts, netloc, e)
+	if err == nil && e.FillDefaultPort && urlParts.HostType == HostName && len(urlParts.Port) == 0 {
+		urlParts.Port, _ = DefaultPort(urlParts.Scheme)
+	}
+	return urlParts, err
+}
+
+// ExtractHost extracts SubDomain, Domain and Suffix from host, a bare hostname or IP address
+// (e.g. www.example.com, 127.0.0.1, ::1 or [::1]). Unlike Extract, host is not trimmed and is not
+// parsed for Scheme, UserInfo, Port or Path, so it must not contain them.
+//
+// For bare hostnames, this is equivalent to Extract with default URLParams, but faster.
+func (f *FastTLD) ExtractHost(host string) (ExtractResult, error) {
+	return f.extractHost(f.suffixTrie(), host)
+}
+
+// RegisteredDomains appends the RegisteredDomain of each of hosts in order, as extracted by ExtractHost,
+// to dst and returns the extended slice. Passing dst[:0] from a previous call reuses its backing array.
+// The RegisteredDomain is empty for hosts that ExtractHost returns an error for, or that have no RegisteredDomain.
+//
+// All hosts are matched against the same suffix trie, even if Update is called concurrently.
+func (f *FastTLD) RegisteredDomains(dst, hosts []string) []string {
+	tldTrie := f.suffixTrie()
+	for _, host := range hosts {
+		var registeredDomain string
+		if res, err := f.extractHost(tldTrie, host); err == nil {
+			registeredDomain = res.RegisteredDomain
+		}
+		dst = append(dst, registeredDomain)
+	}
+	return dst
+}
+
+// ExtractFields splits line around each run of whitespace, as defined by strings.Fields, and extracts
+// URL components from each field with default URLParams.
+//
+// results and errs have one entry per field, in order. errs[i] is nil if results[i] was extracted successfully.
+func (f *FastTLD) ExtractFields(line string) (results []ExtractResult, errs []error) {
+	fields := strings.Fields(line)
+	results, errs = make([]ExtractResult, len(fields)), make([]error, len(fields))
+	for i, field := range fields {
+		results[i], errs[i] = f.Extract(URLParams{URL: field})
+	}
+	return results, errs
+}
+
+// extractHost extracts SubDomain, Domain and Suffix from host, a bare hostname or IP address, using tldTrie.
+func (f *FastTLD) extractHost(tldTrie *trie, host string) (ExtractResult, error) {
+	if len(host) >= 2 && host[0] == '[' && host[len(host)-1] == ']' {
+		if !isIPv6(host[1 : len(host)-1]) {
+			return ExtractResult{}, errors.New("invalid IPv6 address")
+		}
+		host = host[1 : len(host)-1]
+		return ExtractResult{Domain: host, RegisteredDomain: host, HostType: IPv6}, nil
+	}
+	if strings.IndexByte(host, ':') != -1 && isIPv6(host) {
+		return ExtractResult{Domain: host, RegisteredDomain: host, HostType: IPv6}, nil
+	}
+	return f.extractHostName(tldTrie, ExtractResult{}, host, URLParams{})
+}
+
+// extractHostName extracts SubDomain, Domain and Suffix from netloc, a hostname or IPv4 address
+// without Scheme, UserInfo, Port or Path, into urlParts using tldTrie.
+func (f *FastTLD) extractHostName(tldTrie *trie, urlParts ExtractResult, netloc string, e URLParams) (ExtractResult, error) {
+	if e.RejectBadPercentEncoding {
+		if encoding, ok := badPercentEncoding(netloc); ok {
+			return urlParts, fmt.Errorf("%w: %q", ErrBadPercentEncoding, encoding)
+		}
+	}
+
 	// decode all percentage encoded characters, if any
 	unescapedNetloc, err := url.QueryUnescape(netloc)
 	if err != nil {
 		return urlParts, err
 	}
 
-	if e.ConvertURLToPunyCode {
-		netloc = formatAsPunycode(unescapedNetloc)
-	} else if _, err := idna.ToUnicode(unescapedNetloc); err != nil {
-		// host is invalid if host cannot be converted to Unicode
+	if label, ok := invalidPunycodeLabel(unescapedNetloc, e.IDNAProfile.validationProfile()); ok {
+		return urlParts, fmt.Errorf("%w: %q", ErrInvalidPunycode, label)
+	}
+
+	if e.MapIDN && !e.ConvertURLToPunyCode && !e.BothForms {
+		if unescapedNetloc, err = mapIDN(unescapedNetloc, e.IDNAProfile.punycodeProfile()); err != nil {
+			log.Println(strings.SplitAfterN(err.Error(), "idna: invalid label", 2)[0])
+			return urlParts, err
+		}
+		netloc = unescapedNetloc
+	}
+
+	if e.ConvertURLToPunyCode || e.BothForms {
+		netloc = formatAsPunycodeWithProfile(unescapedNetloc, e.IDNAProfile.punycodeProfile())
+	} else if err := e.IDNAProfile.validate(unescapedNetloc); err != nil {
+		// host is invalid if host cannot be converted to Unicode (or to punycode with a non-default profile)
 		//
 		// skip if host already converted to punycode
 		log.Println(strings.SplitAfterN(err.Error(), "idna: invalid label", 2)[0])
 		return urlParts, err
 	}
 
+	if e.RejectMixedScript {
+		if label, ok := mixedScriptLabel(unescapedNetloc); ok {
+			return urlParts, fmt.Errorf("%w: %q", ErrMixedScript, label)
+		}
+	}
+
+	if e.EnforceLabelHyphenRules {
+		if label, ok := invalidHyphenLabel(netloc); ok {
+			return urlParts, fmt.Errorf("%w: %q", ErrLabelHyphen, label)
+		}
+	}
+
+	if e.CollapseSeparators {
+		netloc = collapseSeparators(netloc)
+	}
+
 	// Check for eTLD Suffix
-	node := f.tldTrie
+	node := tldTrie
 
 	var (
 		hasSuffix      bool
@@ -306,6 +1150,11 @@ func (f *FastTLD) Extract(e URLParams) (ExtractResult, error) {
 			end = true
 		}
 
+		if e.CanonicalRegisteredDomain {
+			// match labels in lowercase punycode form (e.g. 例子 as xn--fsqu00a and COM as com)
+			label, _ = canonicalHost(label, e.IDNAProfile.punycodeProfile())
+		}
+
 		if _, ok := node.matches.Get("*"); ok {
 			// check if label falls under any wildcard exception rule
 			// e.g. !www.ck
@@ -341,94 +1190,441 @@ func (f *FastTLD) Extract(e URLParams) (ExtractResult, error) {
 	// Minimum possible length: len("0.0.0.0") -> 7
 	// Ensure first rune is numeric before expensive isIPv4()
 	if len(netloc) >= 7 && numericSet.contains(netloc[0]) && isIPv4(netloc) {
+		if e.RejectIPHosts {
+			return ExtractResult{}, ErrIPHostRejected
+		}
 		urlParts.HostType = IPv4
 		urlParts.Domain = netloc[0:previousSepIdx]
+		if e.CanonicalizeIP {
+			urlParts.Domain = canonicalIP(urlParts.Domain)
+		}
 		urlParts.RegisteredDomain = urlParts.Domain
 		return urlParts, nil
 	}
 
+	// Check for IPv4 address with leading zeros in octets
+	if e.AllowIPv4LeadingZeros && len(netloc) >= 7 && numericSet.contains(netloc[0]) {
+		if ip, ok := parseLeadingZeroIPv4(netloc); ok {
+			if e.RejectIPHosts {
+				return ExtractResult{}, ErrIPHostRejected
+			}
+			urlParts.HostType = IPv4
+			urlParts.Domain = ip
+			urlParts.RegisteredDomain = urlParts.Domain
+			return urlParts, nil
+		}
+	}
+
+	// Check for IPv4 address in hexadecimal, octal or integer form
+	if e.AllowObscureIPv4 && len(netloc) != 0 && numericSet.contains(netloc[0]) {
+		if ip, ok := parseObscureIPv4(netloc); ok {
+			if e.RejectIPHosts {
+				return ExtractResult{}, ErrIPHostRejected
+			}
+			urlParts.HostType = IPv4
+			urlParts.Domain = ip
+			urlParts.RegisteredDomain = urlParts.Domain
+			return urlParts, nil
+		}
+	}
+
+	if e.StrictNumericHost && isNumericHost(netloc) {
+		return urlParts, fmt.Errorf("%w: %q", ErrNumericHost, netloc)
+	}
+
 	if sepIdx == -1 {
 		sepIdx, suffixStartIdx = len(netloc), len(netloc)
 	}
 
 	// Reject if invalidHostNameChars or consecutive label separators
 	// appears before Suffix
+	var invalidChars bool
 	if hasSuffix {
-		if hasInvalidChars(netloc[0:suffixStartIdx]) {
-			return urlParts, errors.New("invalid characters in hostname")
-		}
+		invalidChars = hasInvalidChars(netloc[0:suffixStartIdx])
 	} else {
-		if hasInvalidChars(netloc[0:previousSepIdx]) {
-			return urlParts, errors.New("invalid characters in hostname")
-		}
+		invalidChars = hasInvalidChars(netloc[0:previousSepIdx])
+	}
+	if invalidChars && !e.BestEffort {
+		return urlParts, errors.New("invalid characters in hostname")
 	}
 
 	var domainStartSepIdx int
+	var spans Spans
 	if hasSuffix {
 		if sepIdx < len(netloc) { // If there is a Domain
-			urlParts.Suffix = netloc[sepIdx+sepSize(netloc[sepIdx]) : suffixEndIdx]
+			spans.SuffixStart, spans.SuffixEnd = sepIdx+sepSize(netloc[sepIdx]), suffixEndIdx
 			domainStartSepIdx = lastIndexAny(netloc[0:sepIdx], labelSeparatorsRuneSet)
 			if domainStartSepIdx != -1 { // If there is a SubDomain
-				domainStartIdx := domainStartSepIdx + sepSize(netloc[domainStartSepIdx])
-				urlParts.Domain = netloc[domainStartIdx:sepIdx]
-				urlParts.RegisteredDomain = netloc[domainStartIdx:suffixEndIdx]
-			} else {
-				urlParts.Domain = netloc[0:sepIdx]
-				urlParts.RegisteredDomain = netloc[0:suffixEndIdx]
+				spans.DomainStart = domainStartSepIdx + sepSize(netloc[domainStartSepIdx])
 			}
+			spans.DomainEnd = sepIdx
 		} else {
 			// Only Suffix exists
-			urlParts.Suffix = netloc[0:suffixEndIdx]
+			spans.SuffixEnd = suffixEndIdx
 		}
 	} else {
 		domainStartSepIdx = lastIndexAny(netloc[0:suffixEndIdx], labelSeparatorsRuneSet)
-		var domainStartIdx int
 		if domainStartSepIdx != -1 { // If there is a SubDomain
-			domainStartIdx = domainStartSepIdx + sepSize(netloc[domainStartSepIdx])
+			spans.DomainStart = domainStartSepIdx + sepSize(netloc[domainStartSepIdx])
+		}
+		spans.DomainEnd = suffixEndIdx
+		spans.SuffixStart, spans.SuffixEnd = suffixEndIdx, suffixEndIdx
+	}
+	if e.MaxSubDomainLabels > 0 && domainStartSepIdx > 0 {
+		if n := countLabels(netloc[0:domainStartSepIdx]); n > e.MaxSubDomainLabels {
+			return urlParts, fmt.Errorf("%w: %d", ErrTooManySubDomainLabels, n)
 		}
-		urlParts.Domain = netloc[domainStartIdx:suffixEndIdx]
+	}
+
+	urlParts.Suffix = netloc[spans.SuffixStart:spans.SuffixEnd]
+	urlParts.Domain = netloc[spans.DomainStart:spans.DomainEnd]
+	if len(urlParts.Suffix) != 0 && len(urlParts.Domain) != 0 {
+		urlParts.RegisteredDomain = netloc[spans.DomainStart:spans.SuffixEnd]
 	}
 	if !e.IgnoreSubDomains && domainStartSepIdx != -1 { // If SubDomain is to be included
+		spans.SubDomainEnd = domainStartSepIdx
 		urlParts.SubDomain = netloc[0:domainStartSepIdx]
 	}
 
+	if invalidChars {
+		// best effort: keep Domain, Suffix and RegisteredDomain if only SubDomain has invalid characters
+		if len(urlParts.Suffix) == 0 || len(urlParts.Domain) == 0 || hasInvalidChars(urlParts.Domain) {
+			urlParts.Domain, urlParts.Suffix, urlParts.RegisteredDomain = "", "", ""
+		}
+		urlParts.SubDomain = ""
+		return urlParts, errors.New("invalid characters in hostname")
+	}
+
 	if len(urlParts.Domain) == 0 {
-		return urlParts, errors.New("empty domain")
+		if len(urlParts.Suffix) != 0 && spans.SuffixStart == 0 {
+			return urlParts, fmt.Errorf("%w: %q", ErrSuffixOnly, urlParts.Suffix)
+		}
+		return urlParts, ErrEmptyDomain
 	}
 	urlParts.HostType = HostName
+	if e.LabelVisitor != nil {
+		visitLabels(netloc[0:spans.SuffixEnd], spans.SuffixStart, len(urlParts.Suffix) != 0, e.LabelVisitor)
+	}
+	if e.ReportSpans {
+		urlParts.Spans = spans
+	}
+
+	if e.BothForms {
+		// netloc has already been converted to punycode
+		urlParts.UnicodeSubDomain, _ = idna.ToUnicode(urlParts.SubDomain)
+		urlParts.UnicodeDomain, _ = idna.ToUnicode(urlParts.Domain)
+		urlParts.UnicodeSuffix, _ = idna.ToUnicode(urlParts.Suffix)
+	}
+
+	if e.CanonicalRegisteredDomain && len(urlParts.RegisteredDomain) != 0 {
+		profile := e.IDNAProfile.punycodeProfile()
+		if urlParts.Domain, err = canonicalHost(urlParts.Domain, profile); err != nil {
+			return urlParts, err
+		}
+		if urlParts.Suffix, err = canonicalHost(urlParts.Suffix, profile); err != nil {
+			return urlParts, err
+		}
+		urlParts.RegisteredDomain = urlParts.Domain + "." + urlParts.Suffix
+	}
+
+	// Check for reverse DNS pointer
+	if isReverseDNS, isIPv6Pointer := reverseDNSSuffix(urlParts.Suffix); isReverseDNS {
+		urlParts.IsReverseDNS = true
+		urlParts.ReverseDNSIP = reverseDNSIP(netloc[0:sepIdx], isIPv6Pointer)
+	}
 	return urlParts, nil
 }
 
+// RegistrableInfo extracts the registered domain from a given `url`, and reports whether
+// its Suffix is a PRIVATE domain suffix (e.g. blogspot.com) instead of an ICANN domain suffix.
+func (f *FastTLD) RegistrableInfo(url string) (domain string, private bool, err error) {
+	res, err := f.Extract(URLParams{URL: url})
+	if err != nil {
+		return "", false, err
+	}
+	if node, ok := f.suffixNode(res.Suffix); ok {
+		private = node.private
+	}
+	return res.RegisteredDomain, private, nil
+}
+
+// CandidateSuffixes extracts components from a given `url` with default URLParams, and returns every eTLD that
+// the hostname ends with, longest first (e.g. blogspot.com and com for foo.blogspot.com if private suffixes are
+// included). The first candidate is the Suffix chosen by Extract.
+//
+// Returns nil if `url` is invalid or its hostname has no Suffix.
+func (f *FastTLD) CandidateSuffixes(url string) []string {
+	res, err := f.Extract(URLParams{URL: url})
+	if err != nil || len(res.Suffix) == 0 {
+		return nil
+	}
+	// Suffix is the longest candidate, and may be matched by a wildcard rule (e.g. *.ck)
+	candidates := []string{res.Suffix}
+	for idx, c := range res.Suffix {
+		if labelSeparatorsRuneSet.Exists(c) {
+			if suffix := res.Suffix[idx+sepSize(res.Suffix[idx]):]; f.IsValidPublicSuffix(suffix) {
+				candidates = append(candidates, suffix)
+			}
+		}
+	}
+	return candidates
+}
+
+// CanonicalRegisteredDomain extracts the registered domain from a given `url`, and returns it
+// as lowercase punycode (e.g. 例子.中国, XN--FSQU00A.xn--fiqs8s and xn--fsqu00a.xn--fiqs8s all
+// return xn--fsqu00a.xn--fiqs8s). IP addresses are returned in lowercase.
+//
+// This is the RegisteredDomain returned by Extract with URLParams.CanonicalRegisteredDomain = true.
+func (f *FastTLD) CanonicalRegisteredDomain(url string) (string, error) {
+	res, err := f.Extract(URLParams{URL: url, CanonicalRegisteredDomain: true})
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(res.RegisteredDomain), nil
+}
+
+// ExtractOrigin extracts Scheme, SubDomain, Domain, Suffix and Port from the value of an
+// HTTP Origin header (e.g. https://www.example.com:8443).
+//
+// Returns an error wrapping ErrInvalidOrigin if origin has no Scheme, or has UserInfo or any path, query or fragment.
+// Use Extract for Referer header values, which are full URLs.
+func (f *FastTLD) ExtractOrigin(origin string) (ExtractResult, error) {
+	res, err := f.Extract(URLParams{URL: origin})
+	if err != nil {
+		return res, err
+	}
+	if !res.HadScheme || !strings.HasSuffix(res.Scheme, "//") {
+		return ExtractResult{}, fmt.Errorf("%w: missing scheme", ErrInvalidOrigin)
+	}
+	if len(res.UserInfo) != 0 {
+		return ExtractResult{}, fmt.Errorf("%w: unexpected user info", ErrInvalidOrigin)
+	}
+	if len(res.Path) != 0 {
+		return ExtractResult{}, fmt.Errorf("%w: unexpected path, query or fragment %q", ErrInvalidOrigin, res.Path)
+	}
+	return res, nil
+}
+
+// ExtractEmbedded extracts URL components from the URL embedded in the query parameter `param` of a given `url`
+// (e.g. https://www.example.net/ for http://example.co.uk/path?src=https%3A%2F%2Fwww.example.net%2F and param src).
+// The query parameter value is percent-decoded before extraction. If the query parameter appears more than once,
+// the first one is used.
+//
+// Returns the error from extracting `url` if `url` is invalid, and an error wrapping ErrEmbeddedURLNotFound
+// if `url` has no such query parameter.
+func (f *FastTLD) ExtractEmbedded(url string, param string) (ExtractResult, error) {
+	res, err := f.Extract(URLParams{URL: url, SplitPath: true})
+	if err != nil {
+		return ExtractResult{}, err
+	}
+	embeddedURL, ok := queryParam(res.Query, param)
+	if !ok {
+		return ExtractResult{}, fmt.Errorf("%w: %q", ErrEmbeddedURLNotFound, param)
+	}
+	return f.Extract(URLParams{URL: embeddedURL})
+}
+
+// CacheKey extracts URL components from a given `url`, and returns a normalised form of the URL
+// suitable as a cache key, such that equivalent URLs have the same key. The normalisation rules are:
+//
+//   - Scheme is converted to lowercase. URLs without Scheme have no Scheme in the key.
+//   - UserInfo and Path are unchanged, but an empty Path becomes "/".
+//   - The hostname is converted to lowercase punycode with "." as the label separator, and IP addresses are
+//     converted to their canonical textual form (e.g. [0:0:0:0:0:0:0:1] -> [::1]).
+//   - Port is removed if it is the default port of Scheme (e.g. 443 for https://).
+//   - Empty query parameters are removed, and the remaining query parameters are sorted as
+//     whole name=value strings. Query parameters are not percent-decoded.
+//   - Fragment is removed.
+//
+// Example: CacheKey of HTTPS://WWW.Example.com:443?b=2&a=1#top returns "https://www.example.com/?a=1&b=2".
+func (f *FastTLD) CacheKey(url string) (string, error) {
+	res, err := f.Extract(URLParams{URL: url, ConvertURLToPunyCode: true, CanonicalizeIP: true, SplitPath: true})
+	if err != nil {
+		return "", err
+	}
+	if res.HostType == None {
+		return "", errors.New("URL has no host")
+	}
+	res.Scheme = strings.ToLower(res.Scheme)
+	res.SubDomain = strings.ToLower(res.SubDomain)
+	res.Domain = strings.ToLower(res.Domain)
+	res.Suffix = strings.ToLower(res.Suffix)
+	if len(res.Path) == 0 {
+		res.Path = "/"
+	}
+	var queryParams []string
+	for _, queryParam := range strings.Split(res.Query, "&") {
+		if len(queryParam) != 0 {
+			queryParams = append(queryParams, queryParam)
+		}
+	}
+	sort.Strings(queryParams)
+	res.Query = strings.Join(queryParams, "&")
+	res.Fragment = ""
+	return res.StringOmitDefaultPort(), nil
+}
+
+// IsValidPublicSuffix checks if s exactly matches an eTLD, including eTLDs matched by wildcard rules
+// (e.g. anything.ck matches *.ck) but not their exceptions (e.g. www.ck does not match due to !www.ck).
+func (f *FastTLD) IsValidPublicSuffix(s string) bool {
+	_, ok := f.suffixNode(s)
+	return ok
+}
+
+// IsTLD checks if label is a top level domain (e.g. com, xn--90a3ac, срб), ignoring case.
+// label must be a single label without label separators.
+func (f *FastTLD) IsTLD(label string) bool {
+	if len(label) == 0 || lastIndexAny(label, labelSeparatorsRuneSet) != -1 {
+		return false
+	}
+	node, ok := f.suffixTrie().matches.Get(strings.ToLower(label))
+	return ok && node.end
+}
+
+// Match walks the suffix trie with the labels of host from right to left, without extracting any other URL
+// components, and returns the longest eTLD that host ends with (e.g. "co.uk" for www.example.co.uk).
+// Trailing label separators of host are ignored.
+//
+// isWildcard is true if the last label of matchedSuffix was matched by a wildcard rule (e.g. b.ck for a.b.ck due to *.ck).
+// isException is true if a wildcard rule was not applied due to an exception rule (e.g. ck for www.ck due to !www.ck).
+// Returns false if host does not end with any eTLD.
+func (f *FastTLD) Match(host string) (matchedSuffix string, isWildcard bool, isException bool, ok bool) {
+	host = fastTrim(host, labelSeparatorsRuneSet, trimRight)
+	node := f.suffixTrie()
+	suffixStartIdx := -1
+	sepIdx := len(host)
+	for end := false; !end; {
+		previousSepIdx := sepIdx
+		labelStartIdx := 0
+		sepIdx = lastIndexAny(host[0:sepIdx], labelSeparatorsRuneSet)
+		if sepIdx != -1 {
+			labelStartIdx = sepIdx + sepSize(host[sepIdx])
+		} else {
+			end = true
+		}
+		label := host[labelStartIdx:previousSepIdx]
+		if len(label) == 0 {
+			break
+		}
+		if _, ok := node.matches.Get("*"); ok {
+			// check if label falls under any wildcard exception rule
+			// e.g. !www.ck
+			if _, ok := node.matches.Get("!" + label); ok {
+				isException = true
+			} else {
+				suffixStartIdx, isWildcard = labelStartIdx, true
+			}
+			break
+		}
+		label, _ = url.QueryUnescape(label)
+		val, ok := node.matches.Get(label)
+		if !ok {
+			break
+		}
+		if val.end {
+			suffixStartIdx = labelStartIdx
+		}
+		node = val
+	}
+	if suffixStartIdx == -1 {
+		return "", false, false, false
+	}
+	return host[suffixStartIdx:], isWildcard, isException, true
+}
+
 // New creates a new *FastTLD using data from a Public Suffix List file.
 func New(n SuffixListParams) (*FastTLD, error) {
-	extractor := &FastTLD{cacheFilePath: n.CacheFilePath, tldTrie: &trie{}, includePrivateSuffix: n.IncludePrivateSuffix}
-	// If cacheFilePath is unreachable, use temporary folder
+	if n.SuffixSource != nil {
+		return newFromSuffixSource(n)
+	}
+	filesystem := new(afero.OsFs)
+	extractor := &FastTLD{cacheFilePath: n.CacheFilePath, includePrivateSuffix: n.IncludePrivateSuffix,
+		privateSuffixFilter: n.PrivateSuffixFilter, filesystem: filesystem, extractObserver: n.ExtractObserver,
+		updateTimeout: n.UpdateTimeout}
+	extractor.setSuffixTrie(&trie{}, 0)
+	// If cacheFilePath is unreachable, use CacheDir or temporary folder
 	if isValid, _ := checkCacheFile(extractor.cacheFilePath); !isValid {
-		filesystem := new(afero.OsFs)
-		defaultCacheFolderPath := afero.GetTempDir(filesystem, "")
-		defaultCacheFilePath := defaultCacheFolderPath + defaultPSLFileName
-		defaultCacheFolder, err := filesystem.Open(defaultCacheFolderPath)
+		cacheFilePath, usedCacheDir, err := defaultCacheFilePath(filesystem, n.CacheDir)
 		if err != nil {
 			// temporary folder not accessible, fallback to hardcoded Public Suffix list
 			return newHardcodedPSL(err, n)
 		}
-		defer defaultCacheFolder.Close()
-		extractor.cacheFilePath = defaultCacheFilePath
+		if usedCacheDir {
+			extractor.cacheDir = n.CacheDir
+		}
+		extractor.cacheFilePath = cacheFilePath
 		isValid, lastModifiedHours := checkCacheFile(extractor.cacheFilePath)
 		if !isValid || lastModifiedHours > pslMaxAgeHours {
 			// update Public Suffix list cache if it is outdated
-			if updateErr := extractor.Update(); updateErr != nil {
-				// update failed, fallback to hardcoded Public Suffix list
-				return newHardcodedPSL(err, n)
+			updateTimeout := n.UpdateTimeout
+			if updateTimeout <= 0 {
+				updateTimeout = defaultUpdateTimeout
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+			defer cancel()
+			if updateErr := extractor.updateCacheFile(ctx, publicSuffixListSources); updateErr == nil {
+				return extractor, err
+			} else if !isValid {
+				// update failed and no usable cache, fallback to hardcoded Public Suffix list
+				return newHardcodedPSL(updateErr, n)
 			}
-			return extractor, err
+			// update failed, fallback to outdated Public Suffix list cache
 		}
 	}
 
-	tldTrie, err := trieConstruct(n.IncludePrivateSuffix, extractor.cacheFilePath)
+	tldTrie, numRules, err := trieConstruct(n.IncludePrivateSuffix, n.PrivateSuffixFilter, extractor.cacheFilePath)
 	if err != nil {
 		return newHardcodedPSL(err, n)
 	}
-	extractor.tldTrie = tldTrie
+	extractor.setSuffixTrie(tldTrie, numRules)
 	return extractor, err
 }
+
+// NewFromBytes creates a new *FastTLD using data from psl, the contents of a Public Suffix List file,
+// without reading any files or downloading the Public Suffix List.
+//
+// The returned *FastTLD cannot be updated with Update. Returns an error wrapping ErrIncompletePSL if psl is
+// empty or truncated.
+func NewFromBytes(psl []byte, includePrivateSuffix bool) (*FastTLD, error) {
+	tldTrie, numRules, err := trieConstructFromReader(includePrivateSuffix, nil, bytes.NewReader(psl))
+	if err != nil {
+		return nil, err
+	}
+	extractor := &FastTLD{cacheFilePath: "", includePrivateSuffix: includePrivateSuffix, filesystem: new(afero.OsFs)}
+	extractor.setSuffixTrie(tldTrie, numRules)
+	return extractor, nil
+}
+
+// CacheFilePath returns the path to the Public Suffix List file used to construct
+// the suffix trie, or an empty string if the hardcoded Public Suffix List is used.
+func (f *FastTLD) CacheFilePath() string {
+	return f.cacheFilePath
+}
+
+// Config returns the effective SuffixListParams used to construct f. CacheFilePath is the resolved path
+// to the Public Suffix List file, and is empty if no file is used. CacheDir is empty if CacheDir was not used.
+// UpdateTimeout defaults to 30 seconds. SuffixSource is nil if New fell back to the hardcoded Public Suffix List.
+//
+// The maximum age of the Public Suffix List file and the sources it is downloaded from are not configurable,
+// and are the same for all extractors.
+func (f *FastTLD) Config() SuffixListParams {
+	updateTimeout := f.updateTimeout
+	if updateTimeout <= 0 {
+		updateTimeout = defaultUpdateTimeout
+	}
+	return SuffixListParams{
+		CacheFilePath:        f.cacheFilePath,
+		CacheDir:             f.cacheDir,
+		IncludePrivateSuffix: f.includePrivateSuffix,
+		UpdateTimeout:        updateTimeout,
+		PrivateSuffixFilter:  f.privateSuffixFilter,
+		ExtractObserver:      f.extractObserver,
+		SuffixSource:         f.suffixSource,
+	}
+}
+
+// IncludesPrivateSuffix checks if the suffix trie includes Private Suffixes (e.g. blogspot.com),
+// as specified by SuffixListParams.IncludePrivateSuffix.
+func (f *FastTLD) IncludesPrivateSuffix() bool {
+	return f.includePrivateSuffix
+}
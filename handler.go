@@ -0,0 +1,45 @@
+package fasttld
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Handler returns an http.Handler for debugging, which extracts URL components from
+// the "url" query or form parameter and responds with the JSON-encoded ExtractResult.
+//
+// Responds with status 400 if the "url" parameter is missing, and with status 422 and
+// a JSON object containing the error message in "error" if Extract returns an error.
+func (f *FastTLD) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		url := r.FormValue("url")
+		if len(url) == 0 {
+			http.Error(w, "missing url parameter", http.StatusBadRequest)
+			return
+		}
+		res, err := f.Extract(URLParams{URL: url})
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, struct {
+				Error string `json:"error"`
+			}{err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+}
+
+// writeJSON responds with status and the JSON encoding of v, or with status 500 if v cannot be encoded.
+// Errors from writing the response are logged, as the status has already been sent by then.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,83 @@
+package fasttld
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	extractor, _ := New(SuffixListParams{
+		CacheFilePath: fmt.Sprintf("test%spublic_suffix_list.dat", string(os.PathSeparator)),
+	})
+	server := httptest.NewServer(extractor.Handler())
+	defer server.Close()
+
+	expected := ExtractResult{Scheme: "https://", HadScheme: true, SubDomain: "www", Domain: "example", Suffix: "co.uk",
+		RegisteredDomain: "example.co.uk", Path: "/path?a=1", HostType: HostName}
+	for _, request := range []func() (*http.Response, error){
+		func() (*http.Response, error) {
+			return http.Get(server.URL + "?url=" + url.QueryEscape("https://www.example.co.uk/path?a=1"))
+		},
+		func() (*http.Response, error) {
+			return http.PostForm(server.URL, url.Values{"url": {"https://www.example.co.uk/path?a=1"}})
+		},
+	} {
+		resp, err := request()
+		if err != nil {
+			t.Fatalf("Request failed | %q", err)
+		}
+		var res ExtractResult
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			t.Errorf("Decode failed | %q", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status %d. Got %d.", http.StatusOK, resp.StatusCode)
+		}
+		if contentType := resp.Header.Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Expected Content-Type application/json. Got %q.", contentType)
+		}
+		if !reflect.DeepEqual(res, expected) {
+			t.Errorf("Output %#v not equal to expected %#v", res, expected)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "?url=" + url.QueryEscape("https://example.com:notaport"))
+	if err != nil {
+		t.Fatalf("Request failed | %q", err)
+	}
+	var errorResponse struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
+		t.Errorf("Decode failed | %q", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity || errorResponse.Error != "invalid port" {
+		t.Errorf("Expected status %d and error %q. Got %d and %q.", http.StatusUnprocessableEntity, "invalid port",
+			resp.StatusCode, errorResponse.Error)
+	}
+
+	resp, err = http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed | %q", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status %d. Got %d.", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writeJSON(recorder, http.StatusOK, make(chan int))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d for unencodable value. Got %d.", http.StatusInternalServerError, recorder.Code)
+	}
+}
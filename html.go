@@ -0,0 +1,65 @@
+package fasttld
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ExtractFromHTML extracts URL components from the href and src attributes of every tag in the HTML document read from r,
+// in order of appearance, keeping only the first result for each RegisteredDomain.
+//
+// Relative URLs (e.g. /about or page.html), URLs with opaque schemes (e.g. mailto:) and invalid URLs are skipped.
+// Protocol-relative URLs (e.g. //example.com) are extracted with HadScheme = false.
+// Results without a RegisteredDomain (e.g. localhost) are skipped.
+//
+// Returns an error if the HTML document cannot be read.
+func (f *FastTLD) ExtractFromHTML(r io.Reader) ([]ExtractResult, error) {
+	var results []ExtractResult
+	seen := make(map[string]struct{})
+	tokenizer := html.NewTokenizer(r)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); !errors.Is(err, io.EOF) {
+				return results, err
+			}
+			return results, nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			_, hasAttr := tokenizer.TagName()
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = tokenizer.TagAttr()
+				if k := string(key); k != "href" && k != "src" {
+					continue
+				}
+				res, ok := f.extractLink(string(val))
+				if !ok {
+					continue
+				}
+				if _, ok := seen[res.RegisteredDomain]; ok {
+					continue
+				}
+				seen[res.RegisteredDomain] = struct{}{}
+				results = append(results, res)
+			}
+		}
+	}
+}
+
+// extractLink extracts URL components from link, the value of an href or src attribute.
+// Returns false if link is relative, has an opaque scheme, is invalid or has no RegisteredDomain.
+func (f *FastTLD) extractLink(link string) (ExtractResult, bool) {
+	link = strings.TrimSpace(link)
+	if !strings.HasPrefix(link, "//") && !strings.Contains(Scheme(link), ":") {
+		// relative URL
+		return ExtractResult{}, false
+	}
+	res, err := f.Extract(URLParams{URL: link, ParseOpaqueSchemes: true})
+	if err != nil || res.HostType == None || len(res.RegisteredDomain) == 0 {
+		return ExtractResult{}, false
+	}
+	return res, true
+}
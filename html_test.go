@@ -0,0 +1,59 @@
+package fasttld
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const htmlTestDocument = `<!DOCTYPE html>
+<html>
+<head>
+	<link rel="stylesheet" href="https://cdn.example.com/style.css">
+	<script src="//static.example.co.uk/app.js"></script>
+</head>
+<body>
+	<a href="https://www.example.com/about">About</a>
+	<a href="/contact">Contact</a>
+	<a href="page.html">Page</a>
+	<a href="mailto:someone@example.org">Email</a>
+	<a href="javascript:void(0)">Nothing</a>
+	<A HREF=" http://blog.example.net/?a=1&amp;b=2 ">Blog</A>
+	<img src="https://images.example.net/logo.png"/>
+	<a href="http://localhost:8080">Local</a>
+	<a href="https://[::1]:8443/">IPv6</a>
+	<a href="https://example.com:notaport">Invalid</a>
+	<a title="https://title.example.org">No link</a>
+</body>
+</html>`
+
+func TestExtractFromHTML(t *testing.T) {
+	extractor, _ := New(SuffixListParams{
+		CacheFilePath: fmt.Sprintf("test%spublic_suffix_list.dat", string(os.PathSeparator)),
+	})
+	results, err := extractor.ExtractFromHTML(strings.NewReader(htmlTestDocument))
+	if err != nil {
+		t.Errorf("Expected no error. Got %q.", err)
+	}
+	expected := []ExtractResult{
+		{Scheme: "https://", HadScheme: true, SubDomain: "cdn", Domain: "example", Suffix: "com",
+			RegisteredDomain: "example.com", Path: "/style.css", HostType: HostName},
+		{Scheme: "//", SubDomain: "static", Domain: "example", Suffix: "co.uk",
+			RegisteredDomain: "example.co.uk", Path: "/app.js", HostType: HostName},
+		{Scheme: "http://", HadScheme: true, SubDomain: "blog", Domain: "example", Suffix: "net",
+			RegisteredDomain: "example.net", Path: "/?a=1&b=2", HostType: HostName},
+		{Scheme: "https://", HadScheme: true, Domain: "::1", RegisteredDomain: "::1", Port: "8443", Path: "/", HostType: IPv6},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Output %+v not equal to expected %+v", results, expected)
+	}
+
+	readErr := errors.New("read failed")
+	if _, err := extractor.ExtractFromHTML(iotest.ErrReader(readErr)); !errors.Is(err, readErr) {
+		t.Errorf("Expected read error. Got %v.", err)
+	}
+}
@@ -1,6 +1,10 @@
 package fasttld
 
-import "unicode/utf8"
+import (
+	"net/netip"
+	"strings"
+	"unicode/utf8"
+)
 
 // IP address lengths (bytes).
 const (
@@ -85,9 +89,175 @@ func isIPv4(s string) bool {
 	return len(s) == 0
 }
 
+// parseLeadingZeroIPv4 parses s as a literal IPv4 address in dotted-decimal form where octets may have
+// leading zeros, which are interpreted as decimal (e.g. "192.168.01.1" -> "192.168.1.1").
+//
+// trailing label separators are accepted
+func parseLeadingZeroIPv4(s string) (string, bool) {
+	s = fastTrim(s, labelSeparatorsRuneSet, trimRight)
+	var octets [iPv4len]byte
+	for i := 0; i < iPv4len; i++ {
+		if len(s) == 0 {
+			// Missing octets.
+			return "", false
+		}
+		if i > 0 {
+			r, size := utf8.DecodeRuneInString(s)
+			if !labelSeparatorsRuneSet.Exists(r) {
+				return "", false
+			}
+			s = s[size:]
+		}
+		n, c, ok := dtoi(s)
+		if !ok || n > 0xFF {
+			return "", false
+		}
+		octets[i] = byte(n)
+		s = s[c:]
+	}
+	if len(s) != 0 {
+		return "", false
+	}
+	return netip.AddrFrom4(octets).String(), true
+}
+
+// parseObscureIPv4 parses s as an IPv4 address in any form accepted by web browsers, as described in the
+// WHATWG URL Standard, and returns it in dotted-decimal form (e.g. "0x7f.1" -> "127.0.0.1").
+//
+// s has 1 to 4 parts separated by label separators. Each part is hexadecimal if prefixed with "0x" or "0X",
+// octal if prefixed with "0", and decimal otherwise. The last part fills all remaining bytes of the address
+// (e.g. "2130706433" -> "127.0.0.1" and "127.1" -> "127.0.0.1").
+//
+// trailing label separators are accepted
+func parseObscureIPv4(s string) (string, bool) {
+	s = fastTrim(s, labelSeparatorsRuneSet, trimRight)
+	if len(s) == 0 {
+		return "", false
+	}
+	var parts []uint64
+	start := 0
+	for idx, r := range s + "." {
+		if !labelSeparatorsRuneSet.Exists(r) {
+			continue
+		}
+		n, ok := parseIPv4Number(s[start:idx])
+		if !ok || len(parts) == iPv4len {
+			return "", false
+		}
+		parts = append(parts, n)
+		start = idx + utf8.RuneLen(r)
+	}
+	var addr uint64
+	for _, n := range parts[0 : len(parts)-1] {
+		if n > 0xFF {
+			return "", false
+		}
+		addr = addr<<8 | n
+	}
+	last := parts[len(parts)-1]
+	if last >= 1<<(8*(iPv4len-len(parts)+1)) {
+		return "", false
+	}
+	addr = addr<<(8*(iPv4len-len(parts)+1)) | last
+	return netip.AddrFrom4([4]byte{byte(addr >> 24), byte(addr >> 16), byte(addr >> 8), byte(addr)}).String(), true
+}
+
+// parseIPv4Number parses s as a hexadecimal ("0x" or "0X" prefix), octal ("0" prefix) or decimal number.
+func parseIPv4Number(s string) (uint64, bool) {
+	base := uint64(10)
+	if len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		base, s = 16, s[2:]
+		if len(s) == 0 {
+			// "0x" is 0
+			return 0, true
+		}
+	} else if len(s) >= 2 && s[0] == '0' {
+		base, s = 8, s[1:]
+	}
+	if len(s) == 0 {
+		return 0, false
+	}
+	var n uint64
+	for i := 0; i < len(s); i++ {
+		var d uint64
+		switch c := s[i]; {
+		case '0' <= c && c <= '9':
+			d = uint64(c - '0')
+		case 'a' <= c && c <= 'f':
+			d = uint64(c-'a') + 10
+		case 'A' <= c && c <= 'F':
+			d = uint64(c-'A') + 10
+		default:
+			return 0, false
+		}
+		if d >= base {
+			return 0, false
+		}
+		n = n*base + d
+		if n > 0xFFFFFFFF {
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+// isNumericHost returns true if s consists only of labels of decimal digits (e.g. 1.2.3.4.5 or 127.0.0.256),
+// regardless of whether s is a valid IPv4 address.
+//
+// trailing label separators are accepted
+func isNumericHost(s string) bool {
+	s = fastTrim(s, labelSeparatorsRuneSet, trimRight)
+	if len(s) == 0 {
+		return false
+	}
+	var labelLen int
+	for _, r := range s {
+		if labelSeparatorsRuneSet.Exists(r) {
+			if labelLen == 0 {
+				return false
+			}
+			labelLen = 0
+			continue
+		}
+		if r < '0' || r > '9' {
+			return false
+		}
+		labelLen++
+	}
+	return labelLen != 0
+}
+
+// canonicalIP returns the canonical textual form of IPv4 or IPv6 address s, as described in RFC 5952
+// (e.g. "0:0:0:0:0:0:0:1" -> "::1", "abcd::127.0.0.1" -> "abcd::7f00:1"). Hexadecimal digits are lowercase,
+// label separators are replaced with "." and trailing label separators are removed.
+// IPv4-mapped IPv6 addresses keep their "::ffff:" prefix (e.g. "::ffff:127.0.0.1").
+//
+// s must already be validated by isIPv4 or isIPv6. Returns s unchanged if it cannot be parsed.
+func canonicalIP(s string) string {
+	normalized := fastTrim(strings.Map(func(r rune) rune {
+		if labelSeparatorsRuneSet.Exists(r) {
+			return '.'
+		}
+		return r
+	}, s), labelSeparatorsRuneSet, trimRight)
+	addr, err := netip.ParseAddr(normalized)
+	if err != nil {
+		return s
+	}
+	return addr.String()
+}
+
+// maxIPv6Len is the maximum length in bytes of a literal IPv6 address (e.g. ffff:ffff:ffff:ffff:ffff:ffff:255.255.255.255),
+// where each label separator in the trailing IPv4 address may be up to 3 bytes long (e.g. U+3002).
+const maxIPv6Len = len("ffff:ffff:ffff:ffff:ffff:ffff:255.255.255.255") + 3*(3-1)
+
 // isIPv6 returns true if s is a literal IPv6 address as described in RFC 4291
 // and RFC 5952.
 func isIPv6(s string) bool {
+	if len(s) > maxIPv6Len {
+		// reject early without parsing, e.g. for thousands of zeros
+		return false
+	}
 	ellipsis := -1 // position of ellipsis in ip
 
 	// Might have leading ellipsis
@@ -171,3 +341,83 @@ func isIPv6(s string) bool {
 	}
 	return true
 }
+
+// reverseDNSSuffix checks if suffix is in-addr.arpa or ip6.arpa.
+// isIPv6Pointer is true if suffix is ip6.arpa.
+func reverseDNSSuffix(suffix string) (isReverseDNS bool, isIPv6Pointer bool) {
+	sepIdx := lastIndexAny(suffix, labelSeparatorsRuneSet)
+	if sepIdx == -1 || !strings.EqualFold(suffix[sepIdx+sepSize(suffix[sepIdx]):], "arpa") {
+		return false, false
+	}
+	if firstLabel := suffix[0:sepIdx]; strings.EqualFold(firstLabel, "in-addr") {
+		return true, false
+	} else if strings.EqualFold(firstLabel, "ip6") {
+		return true, true
+	}
+	return false, false
+}
+
+// reverseDNSIP returns the IP address encoded by the labels s of a reverse DNS pointer,
+// e.g. "1.0.0.127" under in-addr.arpa returns "127.0.0.1".
+//
+// Returns an empty string if s does not encode a complete IP address.
+func reverseDNSIP(s string, isIPv6Pointer bool) string {
+	var ip [iPv6len]byte
+	var numLabels int
+	for labelEndIdx := len(s); ; {
+		var label string
+		sepIdx := lastIndexAny(s[0:labelEndIdx], labelSeparatorsRuneSet)
+		if sepIdx != -1 {
+			label = s[sepIdx+sepSize(s[sepIdx]) : labelEndIdx]
+		} else {
+			label = s[0:labelEndIdx]
+		}
+		if isIPv6Pointer {
+			// Each label is a single nibble, starting from the most significant nibble
+			n, c, ok := xtoi(label)
+			if !ok || c != len(label) || c != 1 || numLabels == 2*iPv6len {
+				return ""
+			}
+			if numLabels%2 == 0 {
+				ip[numLabels/2] = byte(n << 4)
+			} else {
+				ip[numLabels/2] |= byte(n)
+			}
+		} else {
+			// Each label is an octet, starting from the first octet
+			n, c, ok := dtoi(label)
+			if !ok || c != len(label) || n > 0xFF || (c > 1 && label[0] == '0') || numLabels == iPv4len {
+				return ""
+			}
+			ip[numLabels] = byte(n)
+		}
+		numLabels++
+		if sepIdx == -1 {
+			break
+		}
+		labelEndIdx = sepIdx
+	}
+	if isIPv6Pointer {
+		if numLabels != 2*iPv6len {
+			return ""
+		}
+		return netip.AddrFrom16(ip).String()
+	}
+	if numLabels != iPv4len {
+		return ""
+	}
+	return netip.AddrFrom4([iPv4len]byte(ip[0:iPv4len])).String()
+}
+
+// reservedTLDs contains the top level domains reserved by RFC 2606 and RFC 6761.
+var reservedTLDs = map[string]struct{}{"test": {}, "example": {}, "invalid": {}, "localhost": {}}
+
+// isReservedTLD checks if the last label of host is a reserved top level domain
+// (test, example, invalid or localhost), ignoring case.
+func isReservedTLD(host string) bool {
+	if sepIdx := lastIndexAny(host, labelSeparatorsRuneSet); sepIdx != -1 {
+		host = host[sepIdx+sepSize(host[sepIdx]):]
+	}
+	_, ok := reservedTLDs[strings.ToLower(host)]
+	return ok
+}
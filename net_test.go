@@ -1,6 +1,9 @@
 package fasttld
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 type looksLikeIPAddressTest struct {
 	maybeIPAddress string
@@ -29,6 +32,15 @@ var looksLikeIPv4AddressTests = []looksLikeIPAddressTest{
 }
 
 var looksLikeIPv6AddressTests = []looksLikeIPAddressTest{
+	{maybeIPAddress: "ffff:ffff:ffff:ffff:ffff:ffff:255\u3002255\uff0e255\uff61255",
+		isIPAddress: true,
+	},
+	{maybeIPAddress: "0ffff:ffff:ffff:ffff:ffff:ffff:255\u3002255\uff0e255\uff61255",
+		isIPAddress: false,
+	},
+	{maybeIPAddress: strings.Repeat("0", 100000) + "1::1",
+		isIPAddress: false,
+	},
 	{maybeIPAddress: "",
 		isIPAddress: false,
 	},
@@ -65,6 +77,84 @@ func TestIsIPv4(t *testing.T) {
 	}
 }
 
+type parseObscureIPv4Test struct {
+	maybeIPAddress string
+	expected       string
+	isIPAddress    bool
+}
+
+var parseObscureIPv4Tests = []parseObscureIPv4Test{
+	{"", "", false},
+	{"google.com", "", false},
+	{"127.0.0.1", "127.0.0.1", true},
+	{"127.0.0.1.", "127.0.0.1", true},
+	{"0x7f.0.0.1", "127.0.0.1", true},
+	{"0X7F.0.0.1", "127.0.0.1", true},
+	{"0x7f000001", "127.0.0.1", true},
+	{"0x7f.1", "127.0.0.1", true},
+	{"0177.0.0.1", "127.0.0.1", true},
+	{"017700000001", "127.0.0.1", true},
+	{"2130706433", "127.0.0.1", true},
+	{"127.1", "127.0.0.1", true},
+	{"192.168.257", "192.168.1.1", true},
+	{"4294967295", "255.255.255.255", true},
+	{"4294967296", "", false},
+	{"256.0.0.1", "", false},
+	{"1.2.3.4.5", "", false},
+	{"1..2", "", false},
+	{"08.0.0.1", "", false},
+	{"0xg.0.0.1", "", false},
+}
+
+func TestParseObscureIPv4(t *testing.T) {
+	for _, test := range parseObscureIPv4Tests {
+		ip, ok := parseObscureIPv4(test.maybeIPAddress)
+		if ok != test.isIPAddress || ip != test.expected {
+			t.Errorf("%q | Output (%q, %t) not equal to expected (%q, %t)",
+				test.maybeIPAddress, ip, ok, test.expected, test.isIPAddress)
+		}
+	}
+}
+
+func TestParseLeadingZeroIPv4(t *testing.T) {
+	for _, test := range []parseObscureIPv4Test{
+		{"192.168.01.1", "192.168.1.1", true},
+		{"010.000.0.0255", "10.0.0.255", true},
+		{"127.0.0.1", "127.0.0.1", true},
+		{"127\uff0e0\u30020\uff6101.", "127.0.0.1", true},
+		{"192.168.01.256", "", false},
+		{"192.168.01", "", false},
+		{"192.168.01.1.1", "", false},
+		{"0x7f.0.0.1", "", false},
+		{"", "", false},
+	} {
+		ip, ok := parseLeadingZeroIPv4(test.maybeIPAddress)
+		if ok != test.isIPAddress || ip != test.expected {
+			t.Errorf("%q | Output (%q, %t) not equal to expected (%q, %t)",
+				test.maybeIPAddress, ip, ok, test.expected, test.isIPAddress)
+		}
+	}
+}
+
+func TestIsNumericHost(t *testing.T) {
+	for _, test := range []looksLikeIPAddressTest{
+		{"1.2.3.4.5", true},
+		{"127.0.0.256", true},
+		{"127.0.0.1.", true},
+		{"1。2", true},
+		{"16777215", true},
+		{"1..2", false},
+		{".1", false},
+		{"1.a", false},
+		{"", false},
+		{".", false},
+	} {
+		if output := isNumericHost(test.maybeIPAddress); output != test.isIPAddress {
+			t.Errorf("%q | Output %t not equal to expected %t", test.maybeIPAddress, output, test.isIPAddress)
+		}
+	}
+}
+
 func TestIsIPv6(t *testing.T) {
 	for _, test := range looksLikeIPv6AddressTests {
 		isIPv6Address := isIPv6(test.maybeIPAddress)
@@ -74,3 +164,44 @@ func TestIsIPv6(t *testing.T) {
 		}
 	}
 }
+
+type reverseDNSIPTest struct {
+	labels        string
+	isIPv6Pointer bool
+	expected      string
+}
+
+var reverseDNSIPTests = []reverseDNSIPTest{
+	{labels: "1.0.0.127", expected: "127.0.0.1"},
+	{labels: "4.3.2.1", expected: "1.2.3.4"},
+	{labels: "0.0.127", expected: ""},
+	{labels: "5.4.3.2.1", expected: ""},
+	{labels: "01.0.0.127", expected: ""},
+	{labels: "1..0.127", expected: ""},
+	{labels: "1.0.0.127", isIPv6Pointer: true, expected: ""},
+	{labels: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0", isIPv6Pointer: true, expected: "::1"},
+	{labels: "0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0", isIPv6Pointer: true, expected: ""},
+	{labels: "g.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0", isIPv6Pointer: true, expected: ""},
+}
+
+func TestReverseDNSIP(t *testing.T) {
+	for _, test := range reverseDNSIPTests {
+		if output := reverseDNSIP(test.labels, test.isIPv6Pointer); output != test.expected {
+			t.Errorf("Output %q not equal to expected %q", output, test.expected)
+		}
+	}
+}
+
+func TestCanonicalIP(t *testing.T) {
+	for _, test := range []struct{ s, expected string }{
+		{"127.0.0.1", "127.0.0.1"},
+		{"127.0.0.1.", "127.0.0.1"},
+		{"0:0:0:0:0:0:0:0", "::"},
+		{"2001:DB8:0:0:0:0:2:1", "2001:db8::2:1"},
+		{"not an ip", "not an ip"},
+	} {
+		if output := canonicalIP(test.s); output != test.expected {
+			t.Errorf("%q | Output %q not equal to expected %q", test.s, output, test.expected)
+		}
+	}
+}
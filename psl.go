@@ -2,13 +2,18 @@ package fasttld
 
 import (
 	"bytes"
+	"context"
+	_ "embed"
 	"errors"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -62,68 +67,155 @@ func processLine(rawLine string, psl suffixes, isPrivateSuffix bool) (suffixes,
 	return psl, isPrivateSuffix
 }
 
-// getPublicSuffixList retrieves Public Suffixes and Private Suffixes from Public Suffix list located at cacheFilePath.
+// getHardcodedPublicSuffixList retrieves Public Suffixes and Private Suffixes from hardcoded Public Suffix list.
 //
 // publicSuffixes: ICANN domains. Example: com, net, org etc.
 //
 // privateSuffixes: PRIVATE domains. Example: blogspot.co.uk, appspot.com etc.
 //
 // allSuffixes: Both ICANN and PRIVATE domains.
-func getPublicSuffixList(cacheFilePath string) (suffixes, error) {
+func getHardcodedPublicSuffixList() (suffixes, error) {
 	var psl suffixes
-	b, err := os.ReadFile(cacheFilePath)
-	if err != nil {
-		log.Println(err)
-		return psl, err
-	}
 	var isPrivateSuffix bool
-	for _, line := range strings.Split(string(b), "\n") {
+	for _, line := range strings.Split(hardcodedPSL, "\n") {
 		psl, isPrivateSuffix = processLine(line, psl, isPrivateSuffix)
 	}
 	return psl, nil
 }
 
-// getHardcodedPublicSuffixList retrieves Public Suffixes and Private Suffixes from hardcoded Public Suffix list.
+// SuffixSource provides Public Suffix List rules for constructing the suffix trie, instead of
+// reading a Public Suffix List file or downloading the Public Suffix List.
 //
-// publicSuffixes: ICANN domains. Example: com, net, org etc.
+// Suffixes returns ICANN rules in publicSuffixes and PRIVATE rules in privateSuffixes, in the same format
+// as lines of a Public Suffix List file (e.g. com, co.uk, *.ck or !www.ck). Rules with non-ASCII characters
+// are also matched in punycode form.
+type SuffixSource interface {
+	Suffixes() (publicSuffixes []string, privateSuffixes []string, err error)
+}
+
+// hardcodedSuffixSource is a SuffixSource providing rules from the hardcoded Public Suffix List.
+type hardcodedSuffixSource struct{}
+
+// Suffixes returns the ICANN and PRIVATE rules of the hardcoded Public Suffix List.
+func (hardcodedSuffixSource) Suffixes() ([]string, []string, error) {
+	psl, err := getHardcodedPublicSuffixList()
+	return psl.publicSuffixes, psl.privateSuffixes, err
+}
+
+// normalizedSuffixSource is a SuffixSource providing rules from source, processed like lines of
+// a Public Suffix List file. Comments and blank rules are skipped, and rules with non-ASCII characters
+// are returned in both punycode and Unicode forms.
+type normalizedSuffixSource struct {
+	source SuffixSource
+}
+
+// Suffixes returns the processed ICANN and PRIVATE rules of s.source.
+func (s normalizedSuffixSource) Suffixes() ([]string, []string, error) {
+	publicSuffixes, privateSuffixes, err := s.source.Suffixes()
+	if err != nil {
+		return nil, nil, err
+	}
+	var psl suffixes
+	for _, suffix := range publicSuffixes {
+		psl, _ = processLine(suffix, psl, false)
+	}
+	for _, suffix := range privateSuffixes {
+		psl, _ = processLine(suffix, psl, true)
+	}
+	return psl.publicSuffixes, psl.privateSuffixes, nil
+}
+
+// NewHardcoded creates a new *FastTLD using data from the hardcoded Public Suffix List bundled with this package,
+// without reading any files or downloading the Public Suffix List.
 //
-// privateSuffixes: PRIVATE domains. Example: blogspot.co.uk, appspot.com etc.
+// The returned *FastTLD cannot be updated with Update.
+func NewHardcoded(includePrivateSuffix bool) (*FastTLD, error) {
+	return newHardcoded(SuffixListParams{IncludePrivateSuffix: includePrivateSuffix})
+}
+
+// testPSL is a small Public Suffix List, embedded from test/mini_public_suffix_list.dat.
 //
-// allSuffixes: Both ICANN and PRIVATE domains.
-func getHardcodedPublicSuffixList() (suffixes, error) {
-	var psl suffixes
-	var isPrivateSuffix bool
-	for _, line := range strings.Split(hardcodedPSL, "\n") {
-		psl, isPrivateSuffix = processLine(line, psl, isPrivateSuffix)
+//go:embed test/mini_public_suffix_list.dat
+var testPSL string
+
+// TestPSL returns a small Public Suffix List for constructing an extractor with predictable results
+// in tests, e.g. with NewFromBytes(TestPSL(), false).
+//
+// ICANN section: ac and its second-level suffixes (com.ac, edu.ac, gov.ac, net.ac, mil.ac, org.ac),
+// the wildcard rule *.ck with the exception !www.ck, and org.sg.
+//
+// Private section: blogspot.com, and xn--0.com which is invalid punycode and is skipped.
+//
+// A new byte slice is returned on every call, so callers may modify it.
+func TestPSL() []byte {
+	return []byte(testPSL)
+}
+
+// newFromSuffixSource creates a new *FastTLD using rules from n.SuffixSource.
+// Falls back to the hardcoded Public Suffix List if n.SuffixSource returns an error.
+func newFromSuffixSource(n SuffixListParams) (*FastTLD, error) {
+	tldTrie, numRules, err := trieConstructFromSource(n.IncludePrivateSuffix, n.PrivateSuffixFilter, normalizedSuffixSource{n.SuffixSource})
+	if err != nil {
+		return newHardcodedPSL(err, n)
 	}
-	return psl, nil
+	extractor := &FastTLD{cacheFilePath: "", includePrivateSuffix: n.IncludePrivateSuffix,
+		privateSuffixFilter: n.PrivateSuffixFilter, filesystem: new(afero.OsFs), extractObserver: n.ExtractObserver,
+		updateTimeout: n.UpdateTimeout, suffixSource: n.SuffixSource}
+	extractor.setSuffixTrie(tldTrie, numRules)
+	return extractor, nil
 }
 
-// newHardcodedPSL creates a new *FastTLD using data from a hardcoded Public Suffix List file.
+// newHardcodedPSL logs err and creates a new *FastTLD using data from a hardcoded Public Suffix List file.
 func newHardcodedPSL(err error, n SuffixListParams) (*FastTLD, error) {
 	log.Println(err, "Fallback to hardcoded Public Suffix List")
-	tldTrie, err := trieConstruct(n.IncludePrivateSuffix, "")
-	return &FastTLD{cacheFilePath: "", tldTrie: tldTrie, includePrivateSuffix: n.IncludePrivateSuffix}, err
+	return newHardcoded(n)
+}
+
+// newHardcoded creates a new *FastTLD using data from a hardcoded Public Suffix List file.
+func newHardcoded(n SuffixListParams) (*FastTLD, error) {
+	tldTrie, numRules, err := trieConstruct(n.IncludePrivateSuffix, n.PrivateSuffixFilter, "")
+	extractor := &FastTLD{cacheFilePath: "", includePrivateSuffix: n.IncludePrivateSuffix,
+		privateSuffixFilter: n.PrivateSuffixFilter, filesystem: new(afero.OsFs), extractObserver: n.ExtractObserver,
+		updateTimeout: n.UpdateTimeout}
+	extractor.setSuffixTrie(tldTrie, numRules)
+	return extractor, err
 }
 
 // downloadFile downloads file from url as byte slice
-func downloadFile(url string) ([]byte, error) {
+func downloadFile(ctx context.Context, url string) ([]byte, error) {
 	// Make HTTP GET request
 	var bodyBytes []byte
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return bodyBytes, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return bodyBytes, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		bodyBytes, err = afero.ReadAll(resp.Body)
-	} else {
+	if resp.StatusCode != http.StatusOK {
 		err = errors.New("Download failed, HTTP status code : " + fmt.Sprint(resp.StatusCode))
+	} else if contentType := resp.Header.Get("Content-Type"); !isPlainTextContentType(contentType) {
+		// e.g. HTML page served by a captive portal
+		err = errors.New("Download failed, unexpected Content-Type : " + contentType)
+	} else {
+		bodyBytes, err = afero.ReadAll(resp.Body)
 	}
 	return bodyBytes, err
 }
 
+// isPlainTextContentType returns true if contentType is empty, text/plain or application/octet-stream.
+// Other media types, including text/html, cannot be a Public Suffix List file.
+func isPlainTextContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/plain" || mediaType == "application/octet-stream")
+}
+
 // getCurrentFilePath returns path to current module file
 //
 // Similar to os.path.dirname(os.path.realpath(__file__)) in Python
@@ -139,22 +231,18 @@ func fileLastModifiedHours(fileinfo os.FileInfo) float64 {
 	return time.Now().Sub(fileinfo.ModTime()).Hours()
 }
 
-// update updates the local cache of Public Suffix List
-func update(file afero.File,
+// update updates the local cache of Public Suffix List at cacheFilePath on filesystem
+func update(ctx context.Context, filesystem afero.Fs, cacheFilePath string,
 	publicSuffixListSources []string) error {
 	for _, publicSuffixListSource := range publicSuffixListSources {
 		// Write GET request body to local file
-		if bodyBytes, err := downloadFile(publicSuffixListSource); err != nil {
+		if bodyBytes, err := downloadFile(ctx, publicSuffixListSource); err != nil {
 			log.Println(err)
 		} else {
 			if !validPSLDelimiters(bodyBytes) {
 				continue
 			}
-			if _, err := file.Seek(0, 0); err != nil {
-				log.Println(err)
-				continue
-			}
-			if _, err := file.Write(bodyBytes); err != nil {
+			if err := writeFileAtomic(filesystem, cacheFilePath, bodyBytes); err != nil {
 				log.Println(err)
 				continue
 			}
@@ -165,11 +253,112 @@ func update(file afero.File,
 	return errors.New("failed to fetch any Public Suffix List from all mirrors")
 }
 
+// writeFileAtomic writes contents to a temporary file in the same folder as filePath,
+// then renames it to filePath, so that filePath is never left partially written.
+// The permission bits of an existing file at filePath are kept, otherwise the file is created with 0644.
+func writeFileAtomic(filesystem afero.Fs, filePath string, contents []byte) error {
+	perm := os.FileMode(0644)
+	if stat, err := filesystem.Stat(filePath); err == nil {
+		perm = stat.Mode().Perm()
+	}
+	tempFile, err := afero.TempFile(filesystem, filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempFilePath := tempFile.Name()
+	_, err = tempFile.Write(contents)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = filesystem.Chmod(tempFilePath, perm)
+	}
+	if err == nil {
+		err = filesystem.Rename(tempFilePath, filePath)
+	}
+	if err != nil {
+		filesystem.Remove(tempFilePath)
+	}
+	return err
+}
+
+// pslDelimiters are the section delimiters of a complete Public Suffix List, in order of appearance.
+var pslDelimiters = []string{
+	"// ===BEGIN ICANN DOMAINS===",
+	"// ===END ICANN DOMAINS===",
+	"// ===BEGIN PRIVATE DOMAINS===",
+	"// ===END PRIVATE DOMAINS===",
+}
+
 func validPSLDelimiters(contents []byte) bool {
-	return bytes.Contains(contents, []byte("// ===BEGIN ICANN DOMAINS===")) &&
-		bytes.Contains(contents, []byte("// ===END ICANN DOMAINS===")) &&
-		bytes.Contains(contents, []byte("// ===BEGIN PRIVATE DOMAINS===")) &&
-		bytes.Contains(contents, []byte("// ===END PRIVATE DOMAINS==="))
+	_, missing := missingPSLDelimiter(contents)
+	return !missing
+}
+
+// missingPSLDelimiter returns the first delimiter in pslDelimiters that is not in contents, if any.
+func missingPSLDelimiter(contents []byte) (string, bool) {
+	for _, delimiter := range pslDelimiters {
+		if !bytes.Contains(contents, []byte(delimiter)) {
+			return delimiter, true
+		}
+	}
+	return "", false
+}
+
+// defaultCacheFilePath returns the path to the Public Suffix List file in cacheDir on filesystem,
+// creating cacheDir if it does not exist. Falls back to the temporary folder if cacheDir is empty
+// or not writable, in which case usedCacheDir is false.
+func defaultCacheFilePath(filesystem afero.Fs, cacheDir string) (cacheFilePath string, usedCacheDir bool, err error) {
+	if cacheDir != "" && isWritableDir(filesystem, cacheDir) {
+		return filepath.Join(cacheDir, defaultPSLFileName), true, nil
+	}
+	tempDir := afero.GetTempDir(filesystem, "")
+	folder, err := filesystem.Open(tempDir)
+	if err != nil {
+		return "", false, err
+	}
+	folder.Close()
+	return tempDir + defaultPSLFileName, false, nil
+}
+
+// checkWritableFile returns an error wrapping ErrCacheFileNotWritable if filePath exists on filesystem
+// but is not a regular file that can be written to. A filePath that does not exist yet passes the check.
+func checkWritableFile(filesystem afero.Fs, filePath string) error {
+	stat, err := filesystem.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !stat.Mode().IsRegular() || stat.Mode().Perm()&0200 == 0 {
+		return fmt.Errorf("%w: %q", ErrCacheFileNotWritable, filePath)
+	}
+	file, err := filesystem.OpenFile(filePath, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrCacheFileNotWritable, filePath)
+	}
+	return file.Close()
+}
+
+// isWritableDir returns true if files can be created in dir on filesystem, creating dir if it does not exist.
+func isWritableDir(filesystem afero.Fs, dir string) bool {
+	if err := filesystem.MkdirAll(dir, 0755); err != nil {
+		return false
+	}
+	if stat, err := filesystem.Stat(dir); err != nil || !stat.IsDir() {
+		return false
+	}
+	file, err := afero.TempFile(filesystem, dir, defaultPSLFileName+".*.tmp")
+	if err != nil {
+		return false
+	}
+	file.Close()
+	filesystem.Remove(file.Name())
+	return true
 }
 
 func checkCacheFile(cacheFilePath string) (bool, float64) {
@@ -187,27 +376,142 @@ func checkCacheFile(cacheFilePath string) (bool, float64) {
 	return pathValidErr == nil && fileinfoErr == nil && !stat.IsDir() && validDelimiters, lastModifiedHours
 }
 
-// Update updates the default Public Suffix list file and updates its suffix trie using the updated file.
-// If cache file path is not the same as the default cache file path, this will be a no-op.
+// Update updates the Public Suffix list file at cache file path and updates its suffix trie using the updated file.
+// If the hardcoded Public Suffix list is in use, this will be a no-op.
+//
+// Returns an error wrapping ErrCacheFileNotWritable without downloading anything if the file at cache file path
+// cannot be written to. The permission bits of the file are kept.
 func (f *FastTLD) Update() error {
-	filesystem := new(afero.OsFs)
-	defaultCacheFilePath := afero.GetTempDir(filesystem, "") + defaultPSLFileName
-
-	if f.cacheFilePath != defaultCacheFilePath {
-		return errors.New("No-op. Only default Public Suffix list file can be updated")
+	if f.cacheFilePath == "" {
+		return errors.New("No-op. Hardcoded Public Suffix list cannot be updated")
 	}
-	file, err := os.OpenFile(defaultCacheFilePath, os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
+	return f.updateCacheFile(context.Background(), publicSuffixListSources)
+}
+
+// updateCacheFile downloads the Public Suffix list from publicSuffixListSources to cache file path
+// and rebuilds the suffix trie from it. Downloads are cancelled when ctx is done.
+func (f *FastTLD) updateCacheFile(ctx context.Context, publicSuffixListSources []string) error {
+	if err := checkWritableFile(f.filesystem, f.cacheFilePath); err != nil {
 		return err
 	}
-	defer file.Close()
-	if updateErr := update(file, publicSuffixListSources); updateErr != nil {
+	if updateErr := update(ctx, f.filesystem, f.cacheFilePath, publicSuffixListSources); updateErr != nil {
 		return updateErr
 	}
-	tldTrie, err := trieConstruct(f.includePrivateSuffix, defaultCacheFilePath)
+	updatedFile, err := f.filesystem.Open(f.cacheFilePath)
+	if err != nil {
+		return err
+	}
+	defer updatedFile.Close()
+	tldTrie, numRules, err := trieConstructFromReader(f.includePrivateSuffix, f.privateSuffixFilter, updatedFile)
 	if err == nil {
-		f.tldTrie = tldTrie
-		f.cacheFilePath = defaultCacheFilePath
+		f.setSuffixTrie(tldTrie, numRules)
 	}
 	return err
 }
+
+// Suffixes returns all eTLD rules in the suffix trie in sorted order, joined at ".".
+// Wildcard rules (e.g. *.ck) also imply their parent suffix (e.g. ck).
+//
+// The rules are cached until the suffix trie is updated, so repeated calls only copy the cached rules.
+func (f *FastTLD) Suffixes() []string {
+	return slices.Clone(f.cachedSuffixes())
+}
+
+// cachedSuffixes returns the cached eTLD rules in the suffix trie, walking the suffix trie if they are not cached.
+// The returned slice must not be modified.
+func (f *FastTLD) cachedSuffixes() []string {
+	state := f.state.Load()
+	state.suffixesOnce.Do(func() {
+		collectSuffixes(state.tldTrie, nil, &state.suffixes)
+		sort.Strings(state.suffixes)
+	})
+	return state.suffixes
+}
+
+// collectSuffixes appends the suffix of every node with end = true under node to suffixList.
+//
+// reversedLabels contains the labels leading to node, in reverse-order.
+func collectSuffixes(node *trie, reversedLabels []string, suffixList *[]string) {
+	if node.end && len(reversedLabels) != 0 {
+		labels := make([]string, len(reversedLabels))
+		copy(labels, reversedLabels)
+		reverse(labels)
+		*suffixList = append(*suffixList, strings.Join(labels, "."))
+	}
+	node.matches.Scan(func(key string, value *trie) bool {
+		collectSuffixes(value, append(reversedLabels, key), suffixList)
+		return true
+	})
+}
+
+// WildcardRule is a wildcard eTLD rule, i.e. every label under Suffix is an eTLD (e.g. *.ck),
+// except for the labels in Exceptions (e.g. www for !www.ck).
+type WildcardRule struct {
+	Suffix     string
+	Exceptions []string
+}
+
+// WildcardRules returns all wildcard rules in the suffix trie in sorted order of Suffix.
+// Exceptions are sorted, and are nil if the wildcard rule has no exceptions.
+func (f *FastTLD) WildcardRules() []WildcardRule {
+	var rules []WildcardRule
+	collectWildcardRules(f.suffixTrie(), nil, &rules)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Suffix < rules[j].Suffix })
+	return rules
+}
+
+// collectWildcardRules appends the wildcard rule of every node with a "*" child under node to rules.
+//
+// reversedLabels contains the labels leading to node, in reverse-order.
+func collectWildcardRules(node *trie, reversedLabels []string, rules *[]WildcardRule) {
+	if _, ok := node.matches.Get("*"); ok && len(reversedLabels) != 0 {
+		labels := make([]string, len(reversedLabels))
+		copy(labels, reversedLabels)
+		reverse(labels)
+		rule := WildcardRule{Suffix: strings.Join(labels, ".")}
+		node.matches.Scan(func(key string, _ *trie) bool {
+			if strings.HasPrefix(key, "!") {
+				rule.Exceptions = append(rule.Exceptions, key[1:])
+			}
+			return true
+		})
+		sort.Strings(rule.Exceptions)
+		*rules = append(*rules, rule)
+	}
+	node.matches.Scan(func(key string, value *trie) bool {
+		collectWildcardRules(value, append(reversedLabels, key), rules)
+		return true
+	})
+}
+
+// NumSuffixes returns the number of eTLD rules the suffix trie was constructed from, as listed in the
+// Public Suffix List (e.g. *.ck and !www.ck are 2 rules, and rules with non-ASCII characters are counted once).
+// The count is stored when the suffix trie is constructed, so calls are cheap. A near-empty count indicates a bad Public Suffix List.
+func (f *FastTLD) NumSuffixes() int {
+	return f.state.Load().numRules
+}
+
+// DiffSuffixes compares the eTLD rules of extractors a and b.
+//
+// added contains rules present in b but not in a, and removed contains rules present in a but not in b.
+// Both are in sorted order.
+func DiffSuffixes(a, b *FastTLD) (added, removed []string) {
+	aSuffixes, bSuffixes := a.Suffixes(), b.Suffixes()
+	var i, j int
+	for i < len(aSuffixes) && j < len(bSuffixes) {
+		switch {
+		case aSuffixes[i] == bSuffixes[j]:
+			i++
+			j++
+		case aSuffixes[i] < bSuffixes[j]:
+			removed = append(removed, aSuffixes[i])
+			i++
+		default:
+			added = append(added, bSuffixes[j])
+			j++
+		}
+	}
+	removed = append(removed, aSuffixes[i:]...)
+	added = append(added, bSuffixes[j:]...)
+	return added, removed
+}
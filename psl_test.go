@@ -1,23 +1,30 @@
 package fasttld
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
+	"github.com/tidwall/hashmap"
 )
 
-type getPublicSuffixListTest struct {
+type trieConstructTest struct {
 	cacheFilePath string
 	expectedLists suffixes
 	hasError      bool
 }
 
-var getPublicSuffixListTests = []getPublicSuffixListTest{
+var trieConstructTests = []trieConstructTest{
 	{cacheFilePath: fmt.Sprintf("test%spublic_suffix_list.dat", string(os.PathSeparator)),
 		expectedLists: pslTestLists,
 		hasError:      false,
@@ -29,30 +36,47 @@ var getPublicSuffixListTests = []getPublicSuffixListTest{
 				"org.ac", "*.ck", "!www.ck", "org.sg", "blogspot.com"}},
 		hasError: false,
 	},
+	{cacheFilePath: fmt.Sprintf("test%struncated_public_suffix_list.dat", string(os.PathSeparator)),
+		expectedLists: suffixes{[]string{}, []string{}, []string{}},
+		hasError:      true,
+	},
 	{cacheFilePath: fmt.Sprintf("test%spublic_suffix_list.dat.noexist", string(os.PathSeparator)),
 		expectedLists: suffixes{[]string{}, []string{}, []string{}},
 		hasError:      true,
 	},
 }
 
-func TestGetPublicSuffixList(t *testing.T) {
-	for _, test := range getPublicSuffixListTests {
-		suffixLists, err := getPublicSuffixList(test.cacheFilePath)
-		if test.hasError && err == nil {
-			t.Errorf("Expected an error. Got no error.")
-		}
-		if !test.hasError && err != nil {
-			t.Errorf("Expected no error. Got an error.")
-		}
-		if output := reflect.DeepEqual(suffixLists,
-			test.expectedLists); !output && (len(suffixLists.publicSuffixes)+
-			len(suffixLists.privateSuffixes)+
-			len(suffixLists.allSuffixes)+
-			len(test.expectedLists.publicSuffixes)+
-			len(test.expectedLists.privateSuffixes)+
-			len(test.expectedLists.allSuffixes)) != 0 {
-			t.Errorf("Output %q not equal to expected %q",
-				suffixLists, test.expectedLists)
+// suffixListsTrie constructs the trie expected from the rules in psl.
+func suffixListsTrie(psl suffixes, includePrivateSuffix bool) *trie {
+	var m hashmap.Map[string, *trie]
+	tldTrie := &trie{matches: m}
+	insertSuffixes(tldTrie, psl.publicSuffixes, false)
+	if includePrivateSuffix {
+		insertSuffixes(tldTrie, psl.privateSuffixes, true)
+	}
+	markWildcardNodes(tldTrie)
+	return tldTrie
+}
+
+func TestTrieConstructSuffixLists(t *testing.T) {
+	for _, test := range trieConstructTests {
+		for _, includePrivateSuffix := range []bool{false, true} {
+			tldTrie, _, err := trieConstruct(includePrivateSuffix, nil, test.cacheFilePath)
+			if test.hasError && err == nil {
+				t.Errorf("Expected an error. Got no error.")
+			}
+			if !test.hasError && err != nil {
+				t.Errorf("Expected no error. Got an error.")
+			}
+			if test.hasError {
+				if tldTrie.matches.Len() != 0 {
+					t.Errorf("Expected an empty trie for %q. Got %d top level keys.", test.cacheFilePath, tldTrie.matches.Len())
+				}
+				continue
+			}
+			if !trieEqual(tldTrie, suffixListsTrie(test.expectedLists, includePrivateSuffix)) {
+				t.Errorf("Trie for %q (includePrivateSuffix = %t) not equal to expected trie", test.cacheFilePath, includePrivateSuffix)
+			}
 		}
 	}
 }
@@ -73,14 +97,149 @@ func TestGetHardcodedPublicSuffixList(t *testing.T) {
 	}
 }
 
+func TestNewHardcoded(t *testing.T) {
+	for _, includePrivateSuffix := range []bool{false, true} {
+		extractor, err := NewHardcoded(includePrivateSuffix)
+		if err != nil {
+			t.Errorf("Expected no error. Got %q.", err)
+		}
+		if extractor.CacheFilePath() != "" {
+			t.Errorf("Expected empty cache file path. Got %q.", extractor.CacheFilePath())
+		}
+		if extractor.IncludesPrivateSuffix() != includePrivateSuffix {
+			t.Errorf("Expected IncludesPrivateSuffix() to be %t. Got %t.", includePrivateSuffix, extractor.IncludesPrivateSuffix())
+		}
+		res, err := extractor.Extract(URLParams{URL: "https://www.google.co.uk/maps"})
+		expected := ExtractResult{Scheme: "https://", HadScheme: true, SubDomain: "www", Domain: "google", Suffix: "co.uk",
+			RegisteredDomain: "google.co.uk", Path: "/maps", HostType: HostName}
+		if err != nil || !reflect.DeepEqual(res, expected) {
+			t.Errorf("Output %#v, %v not equal to expected %#v", res, err, expected)
+		}
+		res, _ = extractor.Extract(URLParams{URL: "foo.blogspot.com"})
+		if expectedSuffix := map[bool]string{false: "com", true: "blogspot.com"}[includePrivateSuffix]; res.Suffix != expectedSuffix {
+			t.Errorf("includePrivateSuffix: %t | Suffix %q not equal to expected %q", includePrivateSuffix, res.Suffix, expectedSuffix)
+		}
+	}
+}
+
+func TestTestPSL(t *testing.T) {
+	cacheFilePath := fmt.Sprintf("test%smini_public_suffix_list.dat", string(os.PathSeparator))
+	miniPSL, err := os.ReadFile(cacheFilePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed | %q", err)
+	}
+	psl := TestPSL()
+	if string(psl) != string(miniPSL) {
+		t.Errorf("TestPSL() not equal to contents of %q", cacheFilePath)
+	}
+	psl[0] = 'x'
+	if TestPSL()[0] != miniPSL[0] {
+		t.Errorf("Modifying the result of TestPSL() should not affect later calls")
+	}
+
+	for _, includePrivateSuffix := range []bool{false, true} {
+		extractor, err := NewFromBytes(TestPSL(), includePrivateSuffix)
+		if err != nil {
+			t.Fatalf("NewFromBytes error: %q", err)
+		}
+		expected, _, _ := trieConstruct(includePrivateSuffix, nil, cacheFilePath)
+		if !trieEqual(extractor.suffixTrie(), expected) {
+			t.Errorf("includePrivateSuffix: %t | Trie not equal to expected trie", includePrivateSuffix)
+		}
+	}
+
+	extractor, _ := NewFromBytes(TestPSL(), false)
+	for url, expectedRegisteredDomain := range map[string]string{
+		"www.example.com.ac": "example.com.ac",
+		"a.b.ck":             "a.b.ck",
+		"www.ck":             "www.ck",
+		"www.example.ac":     "example.ac",
+	} {
+		if res, _ := extractor.Extract(URLParams{URL: url}); res.RegisteredDomain != expectedRegisteredDomain {
+			t.Errorf("%q | RegisteredDomain %q not equal to expected %q", url, res.RegisteredDomain, expectedRegisteredDomain)
+		}
+	}
+}
+
+// memorySuffixSource is an in-memory SuffixSource for tests.
+type memorySuffixSource struct {
+	publicSuffixes, privateSuffixes []string
+	err                             error
+}
+
+func (s memorySuffixSource) Suffixes() ([]string, []string, error) {
+	return s.publicSuffixes, s.privateSuffixes, s.err
+}
+
+func TestSuffixSource(t *testing.T) {
+	source := memorySuffixSource{
+		publicSuffixes:  []string{"com", "co.uk", "uk", "*.ck", "!www.ck", "中国", "// comment", ""},
+		privateSuffixes: []string{"blogspot.com"},
+	}
+	for _, includePrivateSuffix := range []bool{false, true} {
+		extractor, err := New(SuffixListParams{
+			CacheFilePath:        fmt.Sprintf("test%spublic_suffix_list.dat", string(os.PathSeparator)),
+			IncludePrivateSuffix: includePrivateSuffix,
+			SuffixSource:         source,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error. Got %q.", err)
+		}
+		if extractor.CacheFilePath() != "" {
+			t.Errorf("Expected empty cache file path. Got %q.", extractor.CacheFilePath())
+		}
+		if err := extractor.Update(); err == nil {
+			t.Errorf("Expected Update() error. Got no error.")
+		}
+		expectedNumSuffixes := map[bool]int{false: 6, true: 7}[includePrivateSuffix]
+		if numSuffixes := extractor.NumSuffixes(); numSuffixes != expectedNumSuffixes {
+			t.Errorf("includePrivateSuffix: %t | Expected %d suffixes. Got %d: %q.",
+				includePrivateSuffix, expectedNumSuffixes, numSuffixes, extractor.Suffixes())
+		}
+		for url, expectedRegisteredDomain := range map[string]string{
+			"www.example.co.uk":          "example.co.uk",
+			"a.b.ck":                     "a.b.ck",
+			"www.ck":                     "www.ck",
+			"www.例子.中国":                  "例子.中国",
+			"www.xn--fsqu00a.xn--fiqs8s": "xn--fsqu00a.xn--fiqs8s",
+			"example.org":                "",
+			"foo.blogspot.com":           map[bool]string{false: "blogspot.com", true: "foo.blogspot.com"}[includePrivateSuffix],
+		} {
+			if res, _ := extractor.Extract(URLParams{URL: url}); res.RegisteredDomain != expectedRegisteredDomain {
+				t.Errorf("includePrivateSuffix: %t | %q | RegisteredDomain %q not equal to expected %q",
+					includePrivateSuffix, url, res.RegisteredDomain, expectedRegisteredDomain)
+			}
+		}
+	}
+
+	extractor, err := New(SuffixListParams{SuffixSource: memorySuffixSource{err: errors.New("source unavailable")}})
+	if err != nil {
+		t.Errorf("Expected no error. Got %q.", err)
+	}
+	if res, _ := extractor.Extract(URLParams{URL: "www.example.org"}); res.RegisteredDomain != "example.org" {
+		t.Errorf("Expected fallback to hardcoded Public Suffix List. Got RegisteredDomain %q.", res.RegisteredDomain)
+	}
+}
+
+func TestHardcodedSuffixSource(t *testing.T) {
+	psl, _ := getHardcodedPublicSuffixList()
+	publicSuffixes, privateSuffixes, err := hardcodedSuffixSource{}.Suffixes()
+	if err != nil || !reflect.DeepEqual(publicSuffixes, psl.publicSuffixes) || !reflect.DeepEqual(privateSuffixes, psl.privateSuffixes) {
+		t.Errorf("Output not equal to hardcoded Public Suffix List")
+	}
+}
+
 func TestNewHardcodedPSL(t *testing.T) {
 	f, err := newHardcodedPSL(nil, SuffixListParams{})
 	if err != nil {
 		t.Errorf("newHardcodedPSL error: %q", err)
 	}
-	if f.tldTrie.matches.Len() == 0 {
+	if f.suffixTrie().matches.Len() == 0 {
 		t.Errorf("tldTrie should not be empty")
 	}
+	if cacheFilePath := f.CacheFilePath(); cacheFilePath != "" {
+		t.Errorf("CacheFilePath should be empty. Got %q.", cacheFilePath)
+	}
 }
 
 func TestDownloadFile(t *testing.T) {
@@ -97,7 +256,7 @@ func TestDownloadFile(t *testing.T) {
 	defer badServer.Close()
 
 	// HTTP Status Code 200
-	res, _ := downloadFile(goodServer.URL)
+	res, _ := downloadFile(context.Background(), goodServer.URL)
 	if output := reflect.DeepEqual(expectedResponse,
 		res); !output {
 		t.Errorf("Output %q not equal to expected %q",
@@ -105,13 +264,13 @@ func TestDownloadFile(t *testing.T) {
 	}
 
 	// HTTP Status Code 404
-	res, _ = downloadFile(badServer.URL)
+	res, _ = downloadFile(context.Background(), badServer.URL)
 	if len(res) != 0 {
 		t.Errorf("Response should be empty.")
 	}
 
 	// Malformed URL
-	res, _ = downloadFile("!example.com")
+	res, _ = downloadFile(context.Background(), "!example.com")
 	if len(res) != 0 {
 		t.Errorf("Response should be empty.")
 	}
@@ -147,8 +306,7 @@ func TestUpdate(t *testing.T) {
 	defer badServer.Close()
 
 	filesystem := new(afero.MemMapFs)
-	file, _ := afero.TempFile(filesystem, "", "ioutil-test")
-	defer file.Close()
+	cacheFilePath := "/cache/public_suffix_list.dat"
 
 	for _, test := range updateTests {
 		var primarySource, fallbackSource string
@@ -165,7 +323,7 @@ func TestUpdate(t *testing.T) {
 
 		// error should only be returned if Public Suffix List with requiredComments cannot
 		// be downloaded from any of the sources.
-		err := update(file, []string{primarySource, fallbackSource})
+		err := update(context.Background(), filesystem, cacheFilePath, []string{primarySource, fallbackSource})
 		if test.expectError && err == nil {
 			t.Errorf("Expected update() error, got no error.")
 		}
@@ -174,12 +332,215 @@ func TestUpdate(t *testing.T) {
 		}
 	}
 
+	// Captive portal returns requiredComments as an HTML page
+	htmlServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><body>" + requiredComments + "</body></html>"))
+		r.Header.Get("") // removes unused parameter warning
+	}))
+	defer htmlServer.Close()
+	if err := update(context.Background(), filesystem, cacheFilePath, []string{htmlServer.URL, htmlServer.URL}); err == nil {
+		t.Errorf("Expected update() error, got no error.")
+	}
+	htmlCacheFilePath := "/cache/html/public_suffix_list.dat"
+	if err := update(context.Background(), filesystem, htmlCacheFilePath, []string{htmlServer.URL, goodServer.URL}); err != nil {
+		t.Errorf("Expected no update() error, got an error | %q", err)
+	}
+	if contents, _ := afero.ReadFile(filesystem, htmlCacheFilePath); string(contents) != requiredComments {
+		t.Errorf("Expected text/html source to be skipped. Got %q.", contents)
+	}
+
 	// None of the servers return content with requiredComments
-	if err := update(file, []string{emptyServer.URL, emptyServer.URL}); err == nil {
+	if err := update(context.Background(), filesystem, cacheFilePath, []string{emptyServer.URL, emptyServer.URL}); err == nil {
 		t.Errorf("Expected update() error, got no error.")
 	}
 }
 
+func TestIsPlainTextContentType(t *testing.T) {
+	for _, test := range []struct {
+		contentType string
+		expected    bool
+	}{
+		{"text/plain; charset=utf-8", true},
+		{"Text/Plain", true},
+		{"application/octet-stream", true},
+		{"", true},
+		{"text/html; charset=utf-8", false},
+		{"application/json", false},
+		{"text/plain; charset", false},
+	} {
+		if output := isPlainTextContentType(test.contentType); output != test.expected {
+			t.Errorf("%q | Output %t not equal to expected %t", test.contentType, output, test.expected)
+		}
+	}
+}
+
+func TestUpdateCustomCacheFilePath(t *testing.T) {
+	miniPSL, err := os.ReadFile(fmt.Sprintf("test%smini_public_suffix_list.dat", string(os.PathSeparator)))
+	if err != nil {
+		t.Fatalf("ReadFile failed | %q", err)
+	}
+	goodServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(miniPSL)
+		r.Header.Get("") // removes unused parameter warning
+	}))
+	defer goodServer.Close()
+	badServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		r.Header.Get("") // removes unused parameter warning
+	}))
+	defer badServer.Close()
+
+	filesystem := new(afero.MemMapFs)
+	cacheFilePath := "/custom/public_suffix_list.dat"
+	// existing cache file is longer than the updated file
+	staleContents := append([]byte("// stale\n"), miniPSL...)
+	if err := afero.WriteFile(filesystem, cacheFilePath, staleContents, 0644); err != nil {
+		t.Fatalf("WriteFile failed | %q", err)
+	}
+	extractor := &FastTLD{cacheFilePath: cacheFilePath, filesystem: filesystem}
+	extractor.setSuffixTrie(&trie{}, 0)
+
+	if err := extractor.updateCacheFile(context.Background(), []string{badServer.URL}); err == nil {
+		t.Errorf("Expected updateCacheFile() error, got no error.")
+	}
+	if extractor.suffixTrie().matches.Len() != 0 {
+		t.Errorf("tldTrie should not change if update fails")
+	}
+	if contents, _ := afero.ReadFile(filesystem, cacheFilePath); !reflect.DeepEqual(contents, staleContents) {
+		t.Errorf("Cache file contents should not change if update fails")
+	}
+	if numSuffixes := extractor.NumSuffixes(); numSuffixes != 0 {
+		t.Errorf("Expected 0 suffixes before update. Got %d.", numSuffixes)
+	}
+
+	if err := extractor.updateCacheFile(context.Background(), []string{badServer.URL, goodServer.URL}); err != nil {
+		t.Errorf("Expected no updateCacheFile() error, got an error | %q", err)
+	}
+	if contents, _ := afero.ReadFile(filesystem, cacheFilePath); !reflect.DeepEqual(contents, miniPSL) {
+		t.Errorf("Cache file contents not equal to downloaded Public Suffix List")
+	}
+	if entries, _ := afero.ReadDir(filesystem, "/custom"); len(entries) != 1 {
+		t.Errorf("Expected only the cache file in cache folder after update. Got %d files.", len(entries))
+	}
+	if lenTrieMatches := extractor.suffixTrie().matches.Len(); lenTrieMatches != 3 {
+		t.Errorf("Expected top level Trie matches map length of 3. Got %d.", lenTrieMatches)
+	}
+	if numSuffixes := extractor.NumSuffixes(); numSuffixes != 10 {
+		t.Errorf("Expected 10 suffixes after update. Got %d.", numSuffixes)
+	}
+	if suffixes := extractor.Suffixes(); len(suffixes) != 11 || suffixes[0] != "!www.ck" {
+		t.Errorf("Expected cached suffixes to be invalidated after update. Got %q.", suffixes)
+	}
+	if extractor.CacheFilePath() != cacheFilePath {
+		t.Errorf("Expected cache file path to be %q. Got %q.", cacheFilePath, extractor.CacheFilePath())
+	}
+
+	// permission bits of the cache file are kept
+	if err := filesystem.Chmod(cacheFilePath, 0600); err != nil {
+		t.Fatalf("Chmod failed | %q", err)
+	}
+	if err := extractor.updateCacheFile(context.Background(), []string{goodServer.URL}); err != nil {
+		t.Errorf("Expected no updateCacheFile() error, got an error | %q", err)
+	}
+	if stat, _ := filesystem.Stat(cacheFilePath); stat.Mode().Perm() != 0600 {
+		t.Errorf("Expected cache file permission bits to be kept as %v. Got %v.", os.FileMode(0600), stat.Mode().Perm())
+	}
+
+	// read-only cache files are left untouched
+	var downloads int
+	countingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write(miniPSL)
+		r.Header.Get("") // removes unused parameter warning
+	}))
+	defer countingServer.Close()
+	readOnlyFilePath := "/custom/read_only_public_suffix_list.dat"
+	if err := afero.WriteFile(filesystem, readOnlyFilePath, staleContents, 0444); err != nil {
+		t.Fatalf("WriteFile failed | %q", err)
+	}
+	readOnlyExtractor := &FastTLD{cacheFilePath: readOnlyFilePath, filesystem: filesystem}
+	readOnlyExtractor.setSuffixTrie(&trie{}, 0)
+	if err := readOnlyExtractor.updateCacheFile(context.Background(), []string{countingServer.URL}); !errors.Is(err, ErrCacheFileNotWritable) {
+		t.Errorf("Expected ErrCacheFileNotWritable for read-only cache file. Got %v.", err)
+	}
+	if downloads != 0 {
+		t.Errorf("Expected no downloads for read-only cache file. Got %d.", downloads)
+	}
+	if contents, _ := afero.ReadFile(filesystem, readOnlyFilePath); !reflect.DeepEqual(contents, staleContents) {
+		t.Errorf("Read-only cache file contents should not change")
+	}
+	if stat, _ := filesystem.Stat(readOnlyFilePath); stat.Mode().Perm() != 0444 {
+		t.Errorf("Expected read-only cache file permission bits to be %v. Got %v.", os.FileMode(0444), stat.Mode().Perm())
+	}
+
+	// read-only filesystems are left untouched
+	readOnlyExtractor.filesystem = afero.NewReadOnlyFs(filesystem)
+	readOnlyExtractor.cacheFilePath = cacheFilePath
+	if err := readOnlyExtractor.updateCacheFile(context.Background(), []string{countingServer.URL}); !errors.Is(err, ErrCacheFileNotWritable) {
+		t.Errorf("Expected ErrCacheFileNotWritable for read-only filesystem. Got %v.", err)
+	}
+	if downloads != 0 {
+		t.Errorf("Expected no downloads for read-only filesystem. Got %d.", downloads)
+	}
+}
+
+func TestDefaultCacheFilePath(t *testing.T) {
+	miniPSL, err := os.ReadFile(fmt.Sprintf("test%smini_public_suffix_list.dat", string(os.PathSeparator)))
+	if err != nil {
+		t.Fatalf("ReadFile failed | %q", err)
+	}
+	goodServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(miniPSL)
+		r.Header.Get("") // removes unused parameter warning
+	}))
+	defer goodServer.Close()
+
+	filesystem := new(afero.MemMapFs)
+	cacheDir := filepath.Join(string(os.PathSeparator)+"custom", "cache")
+	cacheFilePath, usedCacheDir, err := defaultCacheFilePath(filesystem, cacheDir)
+	if err != nil || !usedCacheDir {
+		t.Fatalf("Expected CacheDir %q to be used | %q", cacheDir, err)
+	}
+	if expected := filepath.Join(cacheDir, defaultPSLFileName); cacheFilePath != expected {
+		t.Errorf("Expected cache file path to be %q. Got %q.", expected, cacheFilePath)
+	}
+	if entries, _ := afero.ReadDir(filesystem, cacheDir); len(entries) != 0 {
+		t.Errorf("Expected empty cache folder before update. Got %d files.", len(entries))
+	}
+	if err := update(context.Background(), filesystem, cacheFilePath, []string{goodServer.URL}); err != nil {
+		t.Errorf("Expected no update() error, got an error | %q", err)
+	}
+	if contents, _ := afero.ReadFile(filesystem, cacheFilePath); !reflect.DeepEqual(contents, miniPSL) {
+		t.Errorf("Cache file contents not equal to downloaded Public Suffix List")
+	}
+
+	// CacheDir is a file, fallback to temporary folder
+	if err := filesystem.MkdirAll(os.TempDir(), 0755); err != nil {
+		t.Fatalf("MkdirAll failed | %q", err)
+	}
+	notADir := filepath.Join(string(os.PathSeparator)+"custom", "file")
+	if err := afero.WriteFile(filesystem, notADir, []byte{}, 0644); err != nil {
+		t.Fatalf("WriteFile failed | %q", err)
+	}
+	for _, dir := range []string{notADir, ""} {
+		cacheFilePath, usedCacheDir, err = defaultCacheFilePath(filesystem, dir)
+		if err != nil || usedCacheDir {
+			t.Errorf("Expected fallback to temporary folder for CacheDir %q | %q", dir, err)
+		}
+		if expected := afero.GetTempDir(filesystem, "") + defaultPSLFileName; cacheFilePath != expected {
+			t.Errorf("Expected cache file path to be %q. Got %q.", expected, cacheFilePath)
+		}
+	}
+}
+
+func TestUpdateHardcodedPSL(t *testing.T) {
+	extractor, _ := newHardcodedPSL(nil, SuffixListParams{})
+	if err := extractor.Update(); err == nil {
+		t.Errorf("Expected Update() error for hardcoded Public Suffix List, got no error.")
+	}
+}
+
 func TestFileLastModifiedHours(t *testing.T) {
 	filesystem := new(afero.MemMapFs)
 	file, _ := afero.TempFile(filesystem, "", "ioutil-test")
@@ -189,3 +550,170 @@ func TestFileLastModifiedHours(t *testing.T) {
 	}
 	defer file.Close()
 }
+
+func TestSuffixes(t *testing.T) {
+	extractor, _ := New(SuffixListParams{
+		CacheFilePath:        fmt.Sprintf("test%smini_public_suffix_list.dat", string(os.PathSeparator)),
+		IncludePrivateSuffix: true,
+	})
+	expected := []string{"!www.ck", "*.ck", "ac", "blogspot.com", "ck", "com.ac", "edu.ac", "gov.ac",
+		"mil.ac", "net.ac", "org.ac", "org.sg"}
+	if output := extractor.Suffixes(); !reflect.DeepEqual(output, expected) {
+		t.Errorf("Output %q not equal to expected %q", output, expected)
+	}
+	// modifying the returned suffixes must not modify the cached suffixes
+	extractor.Suffixes()[0] = "modified"
+	if output := extractor.Suffixes(); !reflect.DeepEqual(output, expected) {
+		t.Errorf("Output %q not equal to expected %q after modification", output, expected)
+	}
+}
+
+func TestWildcardRules(t *testing.T) {
+	extractor, _ := New(SuffixListParams{
+		CacheFilePath:        fmt.Sprintf("test%smini_public_suffix_list.dat", string(os.PathSeparator)),
+		IncludePrivateSuffix: true,
+	})
+	expected := []WildcardRule{{Suffix: "ck", Exceptions: []string{"www"}}}
+	if output := extractor.WildcardRules(); !reflect.DeepEqual(output, expected) {
+		t.Errorf("Output %+v not equal to expected %+v", output, expected)
+	}
+
+	extractor, _ = New(SuffixListParams{
+		CacheFilePath: fmt.Sprintf("test%spublic_suffix_list.dat", string(os.PathSeparator)),
+	})
+	var hasJP bool
+	for _, rule := range extractor.WildcardRules() {
+		if rule.Suffix == "kawasaki.jp" {
+			hasJP = true
+			if expected := []string{"city"}; !reflect.DeepEqual(rule.Exceptions, expected) {
+				t.Errorf("kawasaki.jp exceptions %q not equal to expected %q", rule.Exceptions, expected)
+			}
+		}
+	}
+	if !hasJP {
+		t.Errorf("Expected wildcard rule for kawasaki.jp")
+	}
+}
+
+func TestNumSuffixes(t *testing.T) {
+	extractor, _ := New(SuffixListParams{
+		CacheFilePath:        fmt.Sprintf("test%smini_public_suffix_list.dat", string(os.PathSeparator)),
+		IncludePrivateSuffix: true,
+	})
+	// xn--0.com is invalid punycode and is skipped
+	if numSuffixes := extractor.NumSuffixes(); numSuffixes != 11 {
+		t.Errorf("Expected 11 suffixes. Got %d.", numSuffixes)
+	}
+
+	// IDN rules are counted once, although they are inserted in both punycode and Unicode forms
+	extractor, _ = NewFromBytes([]byte("// ===BEGIN ICANN DOMAINS===\n中国\nxn--fiqs8s\n*.ck\n!www.ck\n// ===END ICANN DOMAINS===\n"+
+		"// ===BEGIN PRIVATE DOMAINS===\n// ===END PRIVATE DOMAINS===\n"), false)
+	if numSuffixes := extractor.NumSuffixes(); numSuffixes != 4 {
+		t.Errorf("Expected 4 suffixes. Got %d: %q.", numSuffixes, extractor.Suffixes())
+	}
+
+	cacheFilePath := fmt.Sprintf("test%spublic_suffix_list.dat", string(os.PathSeparator))
+	extractor, _ = New(SuffixListParams{CacheFilePath: cacheFilePath})
+	contents, _ := os.ReadFile(cacheFilePath)
+	var expectedNumSuffixes int
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "// ===END ICANN DOMAINS===" {
+			break
+		}
+		if len(line) != 0 && !strings.HasPrefix(line, "//") {
+			expectedNumSuffixes++
+		}
+	}
+	if numSuffixes := extractor.NumSuffixes(); numSuffixes != expectedNumSuffixes {
+		t.Errorf("Expected %d suffixes. Got %d.", expectedNumSuffixes, numSuffixes)
+	}
+	if numSuffixes := extractor.NumSuffixes(); numSuffixes < 5000 {
+		t.Errorf("Expected at least 5000 suffixes in test Public Suffix List. Got %d.", numSuffixes)
+	}
+}
+
+func TestSuffixesConcurrentUpdate(t *testing.T) {
+	miniPSL, err := os.ReadFile(fmt.Sprintf("test%smini_public_suffix_list.dat", string(os.PathSeparator)))
+	if err != nil {
+		t.Fatalf("ReadFile failed | %q", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(miniPSL)
+		r.Header.Get("") // removes unused parameter warning
+	}))
+	defer server.Close()
+
+	filesystem := new(afero.MemMapFs)
+	extractor := &FastTLD{cacheFilePath: "/custom/public_suffix_list.dat", filesystem: filesystem}
+	extractor.setSuffixTrie(&trie{}, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			extractor.updateCacheFile(context.Background(), []string{server.URL})
+		}()
+		go func() {
+			defer wg.Done()
+			if numSuffixes := extractor.NumSuffixes(); numSuffixes != 0 && numSuffixes != 10 {
+				t.Errorf("Expected 0 or 10 suffixes. Got %d.", numSuffixes)
+			}
+			extractor.Extract(URLParams{URL: "www.example.ac"})
+		}()
+	}
+	wg.Wait()
+	if numSuffixes := extractor.NumSuffixes(); numSuffixes != 10 {
+		t.Errorf("Expected 10 suffixes after update. Got %d.", numSuffixes)
+	}
+}
+
+func TestDiffSuffixes(t *testing.T) {
+	a, _ := New(SuffixListParams{
+		CacheFilePath:        fmt.Sprintf("test%smini_public_suffix_list.dat", string(os.PathSeparator)),
+		IncludePrivateSuffix: true,
+	})
+	b, _ := New(SuffixListParams{
+		CacheFilePath:        fmt.Sprintf("test%smini_public_suffix_list_updated.dat", string(os.PathSeparator)),
+		IncludePrivateSuffix: true,
+	})
+	added, removed := DiffSuffixes(a, b)
+	if expected := []string{"com.sg"}; !reflect.DeepEqual(added, expected) {
+		t.Errorf("Added %q not equal to expected %q", added, expected)
+	}
+	if expected := []string{"mil.ac"}; !reflect.DeepEqual(removed, expected) {
+		t.Errorf("Removed %q not equal to expected %q", removed, expected)
+	}
+	if added, removed := DiffSuffixes(a, a); len(added) != 0 || len(removed) != 0 {
+		t.Errorf("Expected no difference between identical extractors. Got added %q, removed %q", added, removed)
+	}
+}
+
+func TestNewUpdateTimeout(t *testing.T) {
+	// use empty temporary folder, so that New has to download the Public Suffix List
+	t.Setenv("TMPDIR", t.TempDir())
+
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slowServer.Close()
+
+	originalSources := publicSuffixListSources
+	publicSuffixListSources = []string{slowServer.URL}
+	defer func() { publicSuffixListSources = originalSources }()
+
+	start := time.Now()
+	extractor, _ := New(SuffixListParams{UpdateTimeout: 100 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("New should return shortly after UpdateTimeout. Took %s.", elapsed)
+	}
+	if cacheFilePath := extractor.CacheFilePath(); cacheFilePath != "" {
+		t.Errorf("New should fallback to hardcoded Public Suffix List. Got cache file path %q.", cacheFilePath)
+	}
+	if extractor.suffixTrie().matches.Len() == 0 {
+		t.Errorf("tldTrie should not be empty")
+	}
+}
@@ -0,0 +1,316 @@
+package fasttld
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// JoinHost joins the non-empty components among subDomain, domain and suffix with ".".
+//
+// Example: JoinHost("www", "example", "co.uk") returns "www.example.co.uk".
+func JoinHost(subDomain, domain, suffix string) string {
+	var sb strings.Builder
+	for _, component := range []string{subDomain, domain, suffix} {
+		if len(component) == 0 {
+			continue
+		}
+		if sb.Len() != 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(component)
+	}
+	return sb.String()
+}
+
+// Parent returns the host with its leftmost SubDomain label removed.
+//
+// Example: Parent() of a.b.example.com returns "b.example.com", true.
+//
+// If there is no SubDomain, Parent returns the registered domain (or Domain if there is no Suffix) and false.
+func (r ExtractResult) Parent() (string, bool) {
+	if len(r.SubDomain) == 0 {
+		return JoinHost("", r.Domain, r.Suffix), false
+	}
+	var parentSubDomain string
+	for idx, c := range r.SubDomain {
+		if labelSeparatorsRuneSet.Exists(c) {
+			parentSubDomain = r.SubDomain[idx+sepSize(r.SubDomain[idx]):]
+			break
+		}
+	}
+	return JoinHost(parentSubDomain, r.Domain, r.Suffix), true
+}
+
+// HostPort returns the registered domain and Port joined by ":", omitting ":" if there is no Port.
+// IPv6 addresses are enclosed in square brackets, and hostnames without a Suffix (e.g. localhost) use Domain.
+// SubDomain is excluded, so that all hosts under the same registered domain and Port share a key
+// (e.g. for connection pooling).
+//
+// Example: HostPort() of https://www.example.co.uk:8443/path returns "example.co.uk:8443",
+// and HostPort() of http://[::1]:8080 returns "[::1]:8080".
+func (r ExtractResult) HostPort() string {
+	host := JoinHost("", r.Domain, r.Suffix)
+	if r.HostType == IPv6 {
+		host = "[" + host + "]"
+	}
+	if len(r.Port) == 0 {
+		return host
+	}
+	return host + ":" + r.Port
+}
+
+// WithSubDomain returns the host with SubDomain replaced by sub, which is not validated.
+// If sub is empty, SubDomain is removed.
+//
+// Example: WithSubDomain("mail") of a.b.example.com returns "mail.example.com".
+func (r ExtractResult) WithSubDomain(sub string) string {
+	return JoinHost(sub, r.Domain, r.Suffix)
+}
+
+// WithoutWWW returns the host with its leftmost SubDomain label removed if that label is "www", ignoring case.
+// Otherwise, the host is returned unchanged.
+//
+// Example: WithoutWWW() of www.sub.example.com returns "sub.example.com",
+// but WithoutWWW() of www.net returns "www.net" as "www" is the Domain.
+func (r ExtractResult) WithoutWWW() string {
+	label := r.SubDomain
+	for idx, c := range r.SubDomain {
+		if labelSeparatorsRuneSet.Exists(c) {
+			label = r.SubDomain[0:idx]
+			break
+		}
+	}
+	if strings.EqualFold(label, "www") {
+		parent, _ := r.Parent()
+		return parent
+	}
+	return JoinHost(r.SubDomain, r.Domain, r.Suffix)
+}
+
+// HasSuffix checks if Suffix is equal to, or ends with a label separator followed by, any of suffixes.
+// Comparisons are case-insensitive, all label separators are treated as "." and
+// leading or trailing label separators in suffixes are ignored.
+//
+// Example: HasSuffix("uk") returns true for Suffix "co.uk", but HasSuffix("k") returns false.
+func (r ExtractResult) HasSuffix(suffixes ...string) bool {
+	if len(r.Suffix) == 0 {
+		return false
+	}
+	suffix := normalizeSuffix(r.Suffix)
+	for _, s := range suffixes {
+		s = strings.Trim(normalizeSuffix(s), ".")
+		if len(s) == 0 {
+			continue
+		}
+		if suffix == s || (strings.HasSuffix(suffix, s) && suffix[len(suffix)-len(s)-1] == '.') {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeSuffix converts s to lowercase and replaces all label separators with ".".
+func normalizeSuffix(s string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if labelSeparatorsRuneSet.Exists(r) {
+			return '.'
+		}
+		return r
+	}, s))
+}
+
+// Username returns the part of UserInfo before the first ":", or UserInfo if it has no ":".
+// Percent-encoded colons (%3A) do not split UserInfo, and are not decoded.
+//
+// Example: Username() of user%3Aname:pass@example.com returns "user%3Aname".
+func (r ExtractResult) Username() string {
+	if colonIdx := strings.IndexByte(r.UserInfo, ':'); colonIdx != -1 {
+		return r.UserInfo[0:colonIdx]
+	}
+	return r.UserInfo
+}
+
+// Password returns the part of UserInfo after the first ":", and whether UserInfo has a ":".
+// Percent-encoded colons (%3A) do not split UserInfo, and are not decoded.
+//
+// Example: Password() of user%3Aname:pass@example.com returns "pass", true.
+func (r ExtractResult) Password() (string, bool) {
+	if colonIdx := strings.IndexByte(r.UserInfo, ':'); colonIdx != -1 {
+		return r.UserInfo[colonIdx+1:], true
+	}
+	return "", false
+}
+
+// RegistryLabel returns the label of Suffix immediately left of the top level domain (e.g. "co" for example.co.uk),
+// or an empty string if Suffix has only one label (e.g. example.com).
+func (r ExtractResult) RegistryLabel() string {
+	return registryLabel(r.Suffix)
+}
+
+// IsReservedTLD checks if the top level domain of the hostname is reserved by RFC 2606 or RFC 6761
+// (test, example, invalid or localhost), even if it is not in the Public Suffix List (e.g. foo.test or localhost).
+// Returns false for IP addresses.
+func (r ExtractResult) IsReservedTLD() bool {
+	if r.HostType != HostName {
+		return false
+	}
+	if len(r.Suffix) != 0 {
+		return isReservedTLD(r.Suffix)
+	}
+	return isReservedTLD(r.Domain)
+}
+
+// SuffixLabels returns the labels of Suffix from left to right, without label separators
+// (e.g. co and uk for example.co.uk). Internationalised label separators are handled the same as "."
+// (e.g. a｡fk -> a and fk). Returns an empty slice if there is no Suffix.
+func (r ExtractResult) SuffixLabels() []string {
+	return strings.FieldsFunc(r.Suffix, labelSeparatorsRuneSet.Exists)
+}
+
+// SLD returns the second-level domain, i.e. the label before the eTLD (e.g. "example" for www.example.co.uk).
+// This is the same as Domain for hostnames, and is empty for IP addresses.
+//
+// For hostnames with an eTLD, RegisteredDomain is SLD and TLD joined by a label separator.
+func (r ExtractResult) SLD() string {
+	if r.HostType != HostName {
+		return ""
+	}
+	return r.Domain
+}
+
+// TLD returns the eTLD (e.g. "co.uk" for www.example.co.uk).
+// This is the same as Suffix for hostnames, and is empty for IP addresses.
+func (r ExtractResult) TLD() string {
+	if r.HostType != HostName {
+		return ""
+	}
+	return r.Suffix
+}
+
+// SubDomainUnicode returns SubDomain with its punycode labels converted to Unicode,
+// leaving other labels and label separators unchanged.
+//
+// Example: SubDomainUnicode() of xn--fiqs8s.www.example.com returns "中国.www".
+//
+// Returns an error if a punycode label cannot be converted.
+func (r ExtractResult) SubDomainUnicode() (string, error) {
+	var sb strings.Builder
+	labelStartIdx := 0
+	for idx, c := range r.SubDomain {
+		if labelSeparatorsRuneSet.Exists(c) {
+			if err := writeUnicodeLabel(&sb, r.SubDomain[labelStartIdx:idx]); err != nil {
+				return "", err
+			}
+			sepEndIdx := idx + sepSize(r.SubDomain[idx])
+			sb.WriteString(r.SubDomain[idx:sepEndIdx])
+			labelStartIdx = sepEndIdx
+		}
+	}
+	if err := writeUnicodeLabel(&sb, r.SubDomain[labelStartIdx:]); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// writeUnicodeLabel writes label to sb, converting it to Unicode if it is a punycode label.
+func writeUnicodeLabel(sb *strings.Builder, label string) error {
+	if len(label) < 4 || !strings.EqualFold(label[0:4], "xn--") {
+		sb.WriteString(label)
+		return nil
+	}
+	unicodeLabel, err := idna.ToUnicode(strings.ToLower(label))
+	if err != nil {
+		return err
+	}
+	sb.WriteString(unicodeLabel)
+	return nil
+}
+
+// defaultPorts maps URL schemes to their default ports, as defined by the WHATWG URL Standard.
+var defaultPorts = map[string]string{
+	"ftp":   "21",
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// DefaultPort returns the default port of scheme (e.g. "443" for "https" or "https://"), ignoring case.
+// Returns false if scheme has no known default port.
+func DefaultPort(scheme string) (string, bool) {
+	scheme = strings.ToLower(strings.TrimRight(scheme, `:/\`))
+	port, ok := defaultPorts[scheme]
+	return port, ok
+}
+
+// String reconstructs the URL from Scheme, UserInfo, SubDomain, Domain, Suffix, Port, Path, Query and Fragment.
+// SubDomain, Domain and Suffix are joined with JoinHost, and IPv6 addresses are enclosed in square brackets.
+//
+// Example: String() of the result for https://user@www.example.com:8443/a?b=1 returns "https://user@www.example.com:8443/a?b=1".
+func (r ExtractResult) String() string {
+	return r.format(false)
+}
+
+// StringOmitDefaultPort is like String, but omits Port if it is the default port of Scheme
+// (e.g. http://example.com:80/ -> http://example.com/).
+func (r ExtractResult) StringOmitDefaultPort() string {
+	return r.format(true)
+}
+
+// format reconstructs the URL, omitting Port if omitDefaultPort is true and Port is the default port of Scheme.
+func (r ExtractResult) format(omitDefaultPort bool) string {
+	var sb strings.Builder
+	sb.WriteString(r.Scheme)
+	if len(r.UserInfo) != 0 {
+		sb.WriteString(r.UserInfo)
+		sb.WriteByte('@')
+	}
+	if r.HostType == IPv6 {
+		sb.WriteByte('[')
+		sb.WriteString(r.Domain)
+		sb.WriteByte(']')
+	} else {
+		sb.WriteString(JoinHost(r.SubDomain, r.Domain, r.Suffix))
+	}
+	if len(r.Port) != 0 {
+		if defaultPort, ok := DefaultPort(r.Scheme); !omitDefaultPort || !ok || r.Port != defaultPort {
+			sb.WriteByte(':')
+			sb.WriteString(r.Port)
+		}
+	}
+	sb.WriteString(r.Path)
+	if len(r.Query) != 0 {
+		sb.WriteByte('?')
+		sb.WriteString(r.Query)
+	}
+	if len(r.Fragment) != 0 {
+		sb.WriteByte('#')
+		sb.WriteString(r.Fragment)
+	}
+	return sb.String()
+}
+
+// EqualOption specifies fields ignored by ExtractResult.Equal.
+type EqualOption int
+
+// IgnoreHostType and IgnoreInput specify that ExtractResult.Equal ignores HostType and Input respectively.
+const (
+	IgnoreHostType EqualOption = iota
+	IgnoreInput
+)
+
+// Equal checks if all fields of r and other are equal, except for fields ignored by opts.
+//
+// Example: r.Equal(other, IgnoreInput) ignores Input, which is only populated if URLParams.RetainInput = true.
+func (r ExtractResult) Equal(other ExtractResult, opts ...EqualOption) bool {
+	for _, opt := range opts {
+		switch opt {
+		case IgnoreHostType:
+			r.HostType, other.HostType = None, None
+		case IgnoreInput:
+			r.Input, other.Input = "", ""
+		}
+	}
+	return r == other
+}
@@ -2,7 +2,10 @@ package fasttld
 
 import (
 	"log"
+	"net/url"
+	"slices"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/karlseguin/intset"
@@ -37,6 +40,7 @@ var invalidUserInfoCharsSet asciiSet = makeASCIISet(invalidUserInfoChars)
 var schemeFirstCharSet asciiSet = makeASCIISet(alphabets)
 var schemeRemainingCharSet asciiSet = makeASCIISet(alphabets + numbers + "+-.")
 var slashes asciiSet = makeASCIISet(`/\`)
+var hexDigitsSet asciiSet = makeASCIISet(numbers + "abcdefABCDEF")
 
 // asciiSet is a 32-byte value, where each bit represents the presence of a
 // given ASCII character in the set. The 128-bits of the lower 16 bytes,
@@ -88,15 +92,38 @@ func makeRuneSet(s string) (iset *intset.Rune) {
 	return
 }
 
+// getTrimRuneSet returns a set of whitespace runes merged with runes from extraChars.
+//
+// Only the set without extra characters is shared. Merged sets are built on every call,
+// as caching sets for arbitrary extraChars would grow without bound.
+func getTrimRuneSet(extraChars string) *intset.Rune {
+	if len(extraChars) == 0 {
+		return whitespaceRuneSet
+	}
+	return makeRuneSet(whitespace + extraChars)
+}
+
 // ------------------------------------------------------------------------
 
 // getSchemeEndIndex checks if string s begins with a URL Scheme and
 // returns its last index. Returns -1 if no Scheme exists.
-func getSchemeEndIndex(s string) int {
+//
+// At most maxLength bytes are scanned for the Scheme. If s begins with a Scheme that
+// does not end within maxLength bytes, returns -1 and tooLong = true.
+//
+// Only ASCII letters, digits, "+", "-", ".", ":" and slashes are recognised. Full-width look-alikes
+// (e.g. ｈｔｔｐ, ： and ／) are not Scheme characters, so ｈｔｔｐ://example.com has no Scheme.
+func getSchemeEndIndex(s string, maxLength int) (schemeEndIndex int, tooLong bool) {
 	var colon bool
 	var slashCount int
 
 	for i := 0; i < len(s); i++ {
+		if i >= maxLength {
+			// bail out early on pathological inputs (e.g. thousands of slashes),
+			// reporting tooLong only if the rest of s completes a Scheme
+			schemeEndIndex, _ := getSchemeEndIndex(s, len(s)+1)
+			return -1, schemeEndIndex != -1
+		}
 		// first character
 		if i == 0 {
 			// expecting schemeFirstCharSet or slash
@@ -107,7 +134,7 @@ func getSchemeEndIndex(s string) int {
 				slashCount++
 				continue
 			}
-			return -1
+			return -1, false
 		}
 		// second character onwards
 		// if no slashes yet, look for schemeRemainingCharSet or colon
@@ -126,7 +153,7 @@ func getSchemeEndIndex(s string) int {
 				slashCount++
 				continue
 			}
-			return -1
+			return -1, false
 		}
 		// expecting only slashes
 		if slashes.contains(s[i]) {
@@ -134,14 +161,321 @@ func getSchemeEndIndex(s string) int {
 			continue
 		}
 		if slashCount < 2 {
-			return -1
+			return -1, false
 		}
-		return i
+		return i, false
 	}
 	if slashCount >= 2 {
-		return len(s)
+		return len(s), false
 	}
-	return -1
+	return -1, false
+}
+
+// mailtoScheme is the Scheme of email addresses as described in RFC 6068.
+const mailtoScheme string = "mailto:"
+
+// hasMailtoScheme checks if string s begins with the mailto: Scheme, ignoring case.
+func hasMailtoScheme(s string) bool {
+	return len(s) >= len(mailtoScheme) && strings.EqualFold(s[0:len(mailtoScheme)], mailtoScheme)
+}
+
+// invalidScheme checks if string s begins with what looks like a URL Scheme followed by a colon and
+// at least two slashes, but whose first character is a digit (e.g. "1b://"), and returns it without
+// its colon and slashes.
+func invalidScheme(s string) (string, bool) {
+	if len(s) == 0 || !numericSet.contains(s[0]) {
+		return "", false
+	}
+	for i := 1; i < len(s); i++ {
+		if schemeRemainingCharSet.contains(s[i]) {
+			continue
+		}
+		if s[i] == ':' && i+2 < len(s) && slashes.contains(s[i+1]) && slashes.contains(s[i+2]) {
+			return s[0:i], true
+		}
+		break
+	}
+	return "", false
+}
+
+// countLabels returns the number of labels in s, which are separated by any of labelSeparators.
+func countLabels(s string) int {
+	n := 1
+	for _, r := range s {
+		if labelSeparatorsRuneSet.Exists(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// registryLabel returns the label immediately left of the last label in suffix
+// (e.g. "co" for "co.uk" and "ma" for "k12.ma.us"), or an empty string if suffix has only one label.
+func registryLabel(suffix string) string {
+	sepIdx := lastIndexAny(suffix, labelSeparatorsRuneSet)
+	if sepIdx == -1 {
+		return ""
+	}
+	suffix = suffix[0:sepIdx]
+	if sepIdx = lastIndexAny(suffix, labelSeparatorsRuneSet); sepIdx != -1 {
+		return suffix[sepIdx+sepSize(suffix[sepIdx]):]
+	}
+	return suffix
+}
+
+// Scheme returns the Scheme of url including its trailing slashes (e.g. "https://"),
+// or an empty string if url has no Scheme. Surrounding whitespace is ignored.
+//
+// Only ASCII Scheme characters are recognised (e.g. ｈｔｔｐ://example.com has no Scheme).
+//
+// This does not require a Public Suffix List, and is cheaper than Extract.
+func Scheme(url string) string {
+	s := fastTrim(stripByteOrderMarks(url), whitespaceRuneSet, trimBoth)
+	if schemeEndIndex, _ := getSchemeEndIndex(s, defaultMaxSchemeLength); schemeEndIndex != -1 {
+		return s[0:schemeEndIndex]
+	}
+	return ""
+}
+
+// getOpaqueSchemeEndIndex checks if string s begins with a URL Scheme
+// that is not followed by slashes (e.g. "data:" or "javascript:") and
+// returns the index after its colon. Returns -1 if no such Scheme exists,
+// if the Scheme contains a period and is more likely a hostname (e.g. "example.com:notaport"),
+// if the runes after the colon form a port number (e.g. "localhost:8080"),
+// or if the colon is part of UserInfo (e.g. "user:pass@example.com"), except for the mailto: Scheme.
+func getOpaqueSchemeEndIndex(s string) int {
+	if len(s) == 0 || !schemeFirstCharSet.contains(s[0]) {
+		return -1
+	}
+	colonIdx := -1
+	for i := 1; i < len(s); i++ {
+		if s[i] == ':' {
+			colonIdx = i
+			break
+		}
+		if !schemeRemainingCharSet.contains(s[i]) {
+			return -1
+		}
+	}
+	if colonIdx == -1 || strings.IndexByte(s[0:colonIdx], '.') != -1 {
+		return -1
+	}
+	afterColon := s[colonIdx+1:]
+	if len(afterColon) != 0 && slashes.contains(afterColon[0]) {
+		// authority-based scheme (e.g. "http://")
+		return -1
+	}
+	portEndIdx := indexAnyASCII(afterColon, endOfHostWithPortDelimitersSet)
+	if portEndIdx == -1 {
+		portEndIdx = len(afterColon)
+	}
+	if portEndIdx != 0 && isNumeric(afterColon[0:portEndIdx]) {
+		return -1
+	}
+	if hasUserInfo(afterColon) && !hasMailtoScheme(s) {
+		return -1
+	}
+	return colonIdx + 1
+}
+
+// browserInternalSchemes are schemes of browser-internal pages (e.g. about:blank or chrome://settings),
+// which do not have a hostname.
+var browserInternalSchemes = map[string]struct{}{
+	"about":            {},
+	"brave":            {},
+	"chrome":           {},
+	"chrome-extension": {},
+	"chrome-untrusted": {},
+	"edge":             {},
+	"moz-extension":    {},
+	"view-source":      {},
+}
+
+// getBrowserInternalSchemeEndIndex checks if string s begins with a browser-internal Scheme
+// (e.g. "about:" or "chrome://"), ignoring case, and returns the index after its colon and any slashes.
+// Returns -1 if no such Scheme exists, if the runes after the colon form a port number (e.g. "about:8080"),
+// or if they contain UserInfo (e.g. "about:pass@example.com").
+func getBrowserInternalSchemeEndIndex(s string) int {
+	colonIdx := strings.IndexByte(s, ':')
+	if colonIdx == -1 || colonIdx > len("chrome-extension") {
+		return -1
+	}
+	if _, ok := browserInternalSchemes[strings.ToLower(s[0:colonIdx])]; !ok {
+		return -1
+	}
+	afterColon := s[colonIdx+1:]
+	portEndIdx := indexAnyASCII(afterColon, endOfHostWithPortDelimitersSet)
+	if portEndIdx == -1 {
+		portEndIdx = len(afterColon)
+	}
+	if portEndIdx != 0 && isNumeric(afterColon[0:portEndIdx]) {
+		return -1
+	}
+	schemeEndIdx := colonIdx + 1
+	for schemeEndIdx < len(s) && slashes.contains(s[schemeEndIdx]) {
+		schemeEndIdx++
+	}
+	if hasUserInfo(s[schemeEndIdx:]) {
+		return -1
+	}
+	return schemeEndIdx
+}
+
+// hasUserInfo checks if string s has an "@" before any "/", "?", "#", "[" or "]",
+// i.e. s begins with UserInfo followed by a host.
+func hasUserInfo(s string) bool {
+	return indexLastByteBefore(s, '@', invalidUserInfoCharsSet) != -1
+}
+
+// isNumeric checks if every byte of s is an ASCII digit.
+func isNumeric(s string) bool {
+	for _, c := range []byte(s) {
+		if !numericSet.contains(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// isASCII checks if every byte of s is an ASCII character.
+func isASCII(s string) bool {
+	for _, c := range []byte(s) {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// collapseSeparators replaces each run of two or more consecutive label separators between labels in s with ".".
+// Leading and trailing label separators are left unchanged.
+func collapseSeparators(s string) string {
+	var sb strings.Builder
+	var runStartIdx, runLength int
+	lastWrittenIdx := 0
+	hasLabel := false
+	for idx, c := range s {
+		if labelSeparatorsRuneSet.Exists(c) {
+			if runLength == 0 {
+				runStartIdx = idx
+			}
+			runLength++
+			continue
+		}
+		if runLength > 1 && hasLabel {
+			sb.WriteString(s[lastWrittenIdx:runStartIdx])
+			sb.WriteByte('.')
+			lastWrittenIdx = idx
+		}
+		runLength = 0
+		hasLabel = true
+	}
+	if lastWrittenIdx == 0 {
+		return s
+	}
+	sb.WriteString(s[lastWrittenIdx:])
+	return sb.String()
+}
+
+// badPercentEncoding returns the first "%" in s that is not followed by two hexadecimal digits,
+// together with up to two bytes after it (e.g. "%2" or "%zz"). Returns false if there is no such "%".
+func badPercentEncoding(s string) (string, bool) {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		if i+2 < len(s) && hexDigitsSet.contains(s[i+1]) && hexDigitsSet.contains(s[i+2]) {
+			i += 2
+			continue
+		}
+		return s[i:min(i+3, len(s))], true
+	}
+	return "", false
+}
+
+// splitPath splits path into its path, query and fragment components, without their "?" and "#" delimiters.
+//
+// If fragmentBeforeQuery is true, a "?" after "#" ends the fragment and starts the query.
+func splitPath(path string, fragmentBeforeQuery bool) (string, string, string) {
+	pathEndIdx := strings.IndexAny(path, "?#")
+	if pathEndIdx == -1 {
+		return path, "", ""
+	}
+	rest := path[pathEndIdx:]
+	path = path[0:pathEndIdx]
+	var query, fragment string
+	if rest[0] == '#' && fragmentBeforeQuery {
+		fragment = rest[1:]
+		if queryIdx := strings.IndexByte(fragment, '?'); queryIdx != -1 {
+			query = fragment[queryIdx+1:]
+			fragment = fragment[0:queryIdx]
+		}
+		return path, query, fragment
+	}
+	if fragmentIdx := strings.IndexByte(rest, '#'); fragmentIdx != -1 {
+		fragment = rest[fragmentIdx+1:]
+		rest = rest[0:fragmentIdx]
+	}
+	if len(rest) != 0 {
+		query = rest[1:]
+	}
+	return path, query, fragment
+}
+
+// queryParam returns the percent-decoded value of the first query parameter in query named name.
+// Returns false if there is no such query parameter or its value has invalid percent-encoding.
+func queryParam(query, name string) (string, bool) {
+	for _, param := range strings.Split(query, "&") {
+		key, value, _ := strings.Cut(param, "=")
+		if key, err := url.QueryUnescape(key); err != nil || key != name {
+			continue
+		}
+		value, err := url.QueryUnescape(value)
+		return value, err == nil
+	}
+	return "", false
+}
+
+// LooksLikeURL performs a quick heuristic check of whether s may contain a URL,
+// so that obviously invalid inputs can be skipped cheaply before calling Extract.
+//
+// It returns false if s is empty or whitespace-only, is mostly made up of control characters
+// (including C1 controls and the U+2028 and U+2029 separators) or invalid UTF-8, has no letters
+// or digits, or has no label separator, colon or square bracket (e.g. single words and pure numbers).
+//
+// This is not authoritative; Extract may still fail for inputs where LooksLikeURL returns true,
+// and may succeed for some inputs where LooksLikeURL returns false (e.g. localhost).
+func LooksLikeURL(s string) bool {
+	s = fastTrim(s, whitespaceRuneSet, trimBoth)
+	if len(s) == 0 {
+		return false
+	}
+	var numRunes, numInvalidRunes int
+	var hasAlphaNumeric, hasDelimiter bool
+	for _, r := range s {
+		numRunes++
+		if r == utf8.RuneError || unicode.IsControl(r) || r == '\u2028' || r == '\u2029' {
+			numInvalidRunes++
+			continue
+		}
+		if r < utf8.RuneSelf {
+			if alphaNumericSet.contains(byte(r)) {
+				hasAlphaNumeric = true
+				continue
+			}
+			if r == ':' || r == '[' {
+				hasDelimiter = true
+				continue
+			}
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			hasAlphaNumeric = true
+			continue
+		}
+		if labelSeparatorsRuneSet.Exists(r) {
+			hasDelimiter = true
+		}
+	}
+	return numInvalidRunes*2 < numRunes && hasAlphaNumeric && hasDelimiter
 }
 
 // indexAnyASCII returns the index of the first instance of any Unicode code point
@@ -167,8 +501,11 @@ func hasInvalidChars(s string) bool {
 	var isLabelSeparator bool
 	lastByteIdx := len(s) - 1
 	for idx, c := range s {
-		if alphaNumericSet.contains(byte(c)) {
+		if c < utf8.RuneSelf && alphaNumericSet.contains(byte(c)) {
 			// check for alphanumeric characters early to avoid expensive intset search
+			//
+			// non-ASCII runes must not be truncated to alphanumeric bytes (e.g. U+FF61 -> 'a' or U+2A74 -> 't'),
+			// otherwise non-ASCII label separators and invalid characters are not detected
 			isLabelSeparator = false
 			continue
 		}
@@ -233,10 +570,16 @@ func sepSize(r byte) int {
 }
 
 var idnaToPuny *idna.Profile = idna.New(idna.MapForLookup(), idna.Transitional(true), idna.BidiRule(), idna.CheckHyphens(true))
+var idnaToPunyNonTransitional *idna.Profile = idna.New(idna.MapForLookup(), idna.Transitional(false), idna.BidiRule(), idna.CheckHyphens(true))
 
 // formatAsPunycode formats s as punycode.
 func formatAsPunycode(s string) string {
-	asPunyCode, err := idnaToPuny.ToASCII(s)
+	return formatAsPunycodeWithProfile(s, idnaToPuny)
+}
+
+// formatAsPunycodeWithProfile formats s as punycode using IDNA profile p.
+func formatAsPunycodeWithProfile(s string, p *idna.Profile) string {
+	asPunyCode, err := p.ToASCII(s)
 	if err != nil {
 		log.Println(strings.SplitAfterN(err.Error(), "idna: invalid label", 2)[0])
 		return ""
@@ -244,6 +587,51 @@ func formatAsPunycode(s string) string {
 	return asPunyCode
 }
 
+// mapIDN maps the labels of host with profile as when converting to punycode (e.g. uppercase letters to lowercase
+// and full-width letters to ASCII), but keeps non-ASCII labels in Unicode form and punycode labels in punycode form.
+// Label separators are replaced with ".". Returns an error if host has characters disallowed by profile (e.g. U+0378).
+func mapIDN(host string, profile *idna.Profile) (string, error) {
+	labels := strings.Split(strings.Map(func(r rune) rune {
+		if labelSeparatorsRuneSet.Exists(r) {
+			return '.'
+		}
+		return r
+	}, host), ".")
+	for i, label := range labels {
+		if len(label) == 0 {
+			continue
+		}
+		// ToUnicode does not apply transitional processing, so convert to punycode first
+		asPunyCode, err := profile.ToASCII(label)
+		if err != nil {
+			return "", err
+		}
+		if utf8.RuneCountInString(label) == len(label) {
+			// ASCII labels, including punycode labels, are only converted to lowercase
+			labels[i] = strings.ToLower(label)
+			continue
+		}
+		if labels[i], err = profile.ToUnicode(asPunyCode); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// canonicalHost converts host to lowercase punycode with profile, after decoding any percent-encoded characters
+// (e.g. "例子。中国" -> "xn--fsqu00a.xn--fiqs8s" and "Ex%41mple" -> "example").
+func canonicalHost(host string, profile *idna.Profile) (string, error) {
+	unescapedHost, err := url.QueryUnescape(host)
+	if err != nil {
+		return "", err
+	}
+	asPunyCode, err := profile.ToASCII(unescapedHost)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(asPunyCode), nil
+}
+
 // indexLastByteBefore returns the index of the last instance of byte b
 // before any byte in notAfterCharsSet, otherwise -1
 func indexLastByteBefore(s string, b byte, notAfterCharsSet asciiSet) int {
@@ -253,6 +641,195 @@ func indexLastByteBefore(s string, b byte, notAfterCharsSet asciiSet) int {
 	return strings.LastIndexByte(s, b)
 }
 
+// compatibleScripts lists combinations of scripts that are commonly mixed
+// within a single label (e.g. Japanese text mixes Latin, Han, Hiragana and Katakana).
+//
+// Based on the "Highly Restrictive" level of Unicode Technical Standard #39.
+var compatibleScripts = []map[string]bool{
+	{"Latin": true, "Han": true, "Hiragana": true, "Katakana": true},
+	{"Latin": true, "Han": true, "Hangul": true},
+	{"Latin": true, "Han": true, "Bopomofo": true},
+}
+
+// namedScript is a Unicode script table with its name in unicode.Scripts.
+type namedScript struct {
+	name  string
+	table *unicode.RangeTable
+}
+
+// scripts lists the Unicode scripts searched by scriptOf in order. Scripts commonly seen in hostnames
+// are searched first, followed by the remaining scripts in unicode.Scripts in sorted order of name.
+var scripts = makeScripts("Latin", "Common", "Inherited", "Cyrillic", "Greek", "Han", "Hiragana", "Katakana",
+	"Hangul", "Bopomofo", "Arabic", "Hebrew", "Thai", "Devanagari", "Armenian", "Georgian")
+
+// makeScripts returns the tables of the scripts named by first in order, followed by all other
+// scripts in unicode.Scripts in sorted order of name.
+func makeScripts(first ...string) []namedScript {
+	names := append(make([]string, 0, len(unicode.Scripts)), first...)
+	for name := range unicode.Scripts {
+		if !slices.Contains(first, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names[len(first):])
+	scripts := make([]namedScript, len(names))
+	for i, name := range names {
+		scripts[i] = namedScript{name, unicode.Scripts[name]}
+	}
+	return scripts
+}
+
+// scriptOf returns the name of the Unicode script that r belongs to.
+func scriptOf(r rune) string {
+	if r < utf8.RuneSelf {
+		// check for ASCII characters early to avoid expensive script table search
+		if alphaNumericSet.contains(byte(r)) && !numericSet.contains(byte(r)) {
+			return "Latin"
+		}
+		return "Common"
+	}
+	for _, script := range scripts {
+		if unicode.Is(script.table, r) {
+			return script.name
+		}
+	}
+	return "Unknown"
+}
+
+// isMixedScript checks if s contains characters from more than one script,
+// ignoring characters shared by all scripts (e.g. digits and hyphens), and
+// allowing combinations listed in compatibleScripts.
+func isMixedScript(s string) bool {
+	scripts := make(map[string]bool)
+	for _, r := range s {
+		if script := scriptOf(r); script != "Common" && script != "Inherited" {
+			scripts[script] = true
+		}
+	}
+	if len(scripts) <= 1 {
+		return false
+	}
+	for _, compatible := range compatibleScripts {
+		isSubset := true
+		for script := range scripts {
+			if !compatible[script] {
+				isSubset = false
+				break
+			}
+		}
+		if isSubset {
+			return false
+		}
+	}
+	return true
+}
+
+// mixedScriptLabel returns the first label in host that contains characters from more than one script.
+// Punycode labels are checked in their Unicode form. Returns false if there is no such label.
+func mixedScriptLabel(host string) (string, bool) {
+	for _, label := range strings.FieldsFunc(host, labelSeparatorsRuneSet.Exists) {
+		unicodeLabel, err := idna.ToUnicode(label)
+		if err != nil {
+			unicodeLabel = label
+		}
+		if isMixedScript(unicodeLabel) {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+// invalidHyphenLabel returns the first label in host that starts or ends with "-", or has "--" in the third
+// and fourth positions but is not a valid punycode label. Returns false if there is no such label.
+func invalidHyphenLabel(host string) (string, bool) {
+	for _, label := range strings.FieldsFunc(host, labelSeparatorsRuneSet.Exists) {
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return label, true
+		}
+		if len(label) >= 4 && label[2:4] == "--" {
+			if !strings.EqualFold(label[0:2], "xn") {
+				return label, true
+			}
+			if _, err := idna.Punycode.ToUnicode(strings.ToLower(label)); err != nil {
+				return label, true
+			}
+		}
+	}
+	return "", false
+}
+
+// invalidPunycodeLabel returns the first label of host that begins with "xn--", ignoring case,
+// but cannot be converted to Unicode with profile (e.g. xn--0).
+func invalidPunycodeLabel(host string, profile *idna.Profile) (string, bool) {
+	if !strings.Contains(host, "--") {
+		return "", false
+	}
+	for _, label := range strings.FieldsFunc(host, labelSeparatorsRuneSet.Exists) {
+		if len(label) < 4 || !strings.EqualFold(label[0:4], "xn--") {
+			continue
+		}
+		if _, err := profile.ToUnicode(strings.ToLower(label)); err != nil {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+// normalizeBackslashes converts backslashes in path to slashes, up to the first "?" or "#".
+func normalizeBackslashes(path string) string {
+	end := strings.IndexAny(path, "?#")
+	if end == -1 {
+		end = len(path)
+	}
+	if strings.IndexByte(path[0:end], '\\') == -1 {
+		return path
+	}
+	return strings.ReplaceAll(path[0:end], `\`, "/") + path[end:]
+}
+
+// decodePath percent-decodes path with url.PathUnescape up to the first "?" or "#", leaving any query and
+// fragment unchanged. Percent-encoded "?" and "#" (%3F and %23) are kept encoded, so that they do not
+// become the start of a query or fragment.
+func decodePath(path string) (string, error) {
+	end := strings.IndexAny(path, "?#")
+	if end == -1 {
+		end = len(path)
+	}
+	var sb strings.Builder
+	segmentStartIdx := 0
+	for i := 0; i+2 < end; i++ {
+		if path[i] != '%' || !(strings.EqualFold(path[i+1:i+3], "3f") || path[i+1:i+3] == "23") {
+			continue
+		}
+		decodedSegment, err := url.PathUnescape(path[segmentStartIdx:i])
+		if err != nil {
+			return path, err
+		}
+		sb.WriteString(decodedSegment)
+		sb.WriteString(path[i : i+3])
+		segmentStartIdx = i + 3
+		i += 2
+	}
+	decodedSegment, err := url.PathUnescape(path[segmentStartIdx:end])
+	if err != nil {
+		return path, err
+	}
+	sb.WriteString(decodedSegment)
+	sb.WriteString(path[end:])
+	return sb.String(), nil
+}
+
+// byteOrderMark is the UTF-8 encoding of U+FEFF (EF BB BF).
+const byteOrderMark string = "\ufeff"
+
+// stripByteOrderMarks removes all leading UTF-8 byte order marks from s.
+func stripByteOrderMarks(s string) string {
+	for strings.HasPrefix(s, byteOrderMark) {
+		s = s[len(byteOrderMark):]
+	}
+	return s
+}
+
 // trimMode specifies which parts of string to trim for fastTrim()
 type trimMode int
 
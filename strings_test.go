@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"unicode"
 
 	"github.com/karlseguin/intset"
 )
@@ -78,3 +79,185 @@ func TestFastTrim(t *testing.T) {
 		}
 	}
 }
+
+func TestGetTrimRuneSet(t *testing.T) {
+	if getTrimRuneSet("") != whitespaceRuneSet {
+		t.Errorf("getTrimRuneSet with no extra characters should return whitespaceRuneSet")
+	}
+	iset := getTrimRuneSet("§")
+	if !iset.Exists('§') || !iset.Exists(' ') {
+		t.Errorf("getTrimRuneSet should contain whitespace and extra characters")
+	}
+	if getTrimRuneSet("§") == iset {
+		t.Errorf("getTrimRuneSet should not cache rune sets with extra characters")
+	}
+}
+
+type looksLikeURLTest struct {
+	s        string
+	expected bool
+}
+
+var looksLikeURLTests = []looksLikeURLTest{
+	{"https://www.example.com/path", true},
+	{"  example.com  ", true},
+	{"example。com", true},
+	{"localhost:8080", true},
+	{"[::1]", true},
+	{"127.0.0.1", true},
+	{"mailto:user@example.com", true},
+	{"https://例子.测试", true},
+	{"", false},
+	{" \t\n ", false},
+	{"localhost", false},
+	{"1234567890", false},
+	{"...", false},
+	{"a\x00\x01\x02\x03\x04.b", false},
+	{"\xff\xfe\xfd.a", false},
+	{"a\u0080\u0085\u009f\u2028\u2029.b", false},
+}
+
+func TestLooksLikeURL(t *testing.T) {
+	for _, test := range looksLikeURLTests {
+		if output := LooksLikeURL(test.s); output != test.expected {
+			t.Errorf("%q | Output %t not equal to expected %t", test.s, output, test.expected)
+		}
+	}
+}
+
+func TestScheme(t *testing.T) {
+	for _, test := range []struct{ url, expected string }{
+		{"https://www.example.com/path", "https://"},
+		{" \tgit+ssh://git@example.com/repo.git ", "git+ssh://"},
+		{"//example.com", "//"},
+		{"hTtP:\\\\example.com", `hTtP:\\`},
+		{"example.com/path", ""},
+		{"localhost:8080", ""},
+		{"", ""},
+		{"http:" + strings.Repeat("/", 10000) + "example.com", ""},
+		{"\uff48\uff54\uff54\uff50://example.com", ""},
+		{"\uff48ttp://example.com", ""},
+		{"http\uff1a//example.com", ""},
+		{"http:\uff0f\uff0fexample.com", ""},
+	} {
+		if output := Scheme(test.url); output != test.expected {
+			t.Errorf("%q | Output %q not equal to expected %q", test.url, output, test.expected)
+		}
+	}
+}
+
+func TestInvalidScheme(t *testing.T) {
+	for _, test := range []struct {
+		s, expected string
+		ok          bool
+	}{
+		{"1b://example.com", "1b", true},
+		{"9://x", "9", true},
+		{`9a+b.c:\\x`, "9a+b.c", true},
+		{"1b:/example.com", "", false},
+		{"1b://", "1b", true},
+		{"1b:/", "", false},
+		{"127.0.0.1:8080//path", "", false},
+		{"b1://example.com", "", false},
+		{"1_b://example.com", "", false},
+		{"", "", false},
+	} {
+		if output, ok := invalidScheme(test.s); output != test.expected || ok != test.ok {
+			t.Errorf("%q | Output (%q, %t) not equal to expected (%q, %t)", test.s, output, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestQueryParam(t *testing.T) {
+	for _, test := range []struct {
+		query, name, expected string
+		ok                    bool
+	}{
+		{"a=1&src=https%3A%2F%2Fwww.example.net%2F", "src", "https://www.example.net/", true},
+		{"src=first&src=second", "src", "first", true},
+		{"a+b=c+d", "a b", "c d", true},
+		{"src", "src", "", true},
+		{"a=1", "src", "", false},
+		{"src=%zz", "src", "", false},
+		{"", "src", "", false},
+	} {
+		if output, ok := queryParam(test.query, test.name); output != test.expected || ok != test.ok {
+			t.Errorf("%q | Output (%q, %t) not equal to expected (%q, %t)", test.query, output, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestDecodePath(t *testing.T) {
+	for _, test := range []struct {
+		path     string
+		expected string
+		hasError bool
+	}{
+		{"/a%20b", "/a b", false},
+		{"/a%20b?x=%20#%20", "/a b?x=%20#%20", false},
+		{"/a%20b#%20?x=%20", "/a b#%20?x=%20", false},
+		{"/a%3Fb%3f%23%20", "/a%3Fb%3f%23 ", false},
+		{"/%3F", "/%3F", false},
+		{"/a%3", "/a%3", true},
+		{"/a%2?x=%zz", "/a%2?x=%zz", true},
+		{"", "", false},
+	} {
+		output, err := decodePath(test.path)
+		if output != test.expected || (err != nil) != test.hasError {
+			t.Errorf("%q | Output (%q, %v) not equal to expected (%q, error: %t)", test.path, output, err, test.expected, test.hasError)
+		}
+	}
+}
+
+func TestHasInvalidChars(t *testing.T) {
+	for _, test := range []struct {
+		s        string
+		expected bool
+	}{
+		{"a.fk.", false},
+		{"a\uff61fk\uff61", false},
+		{"a.fk..", true},
+		{"a\uff61fk\uff61\uff61", true},
+		{"a\uff61fk\u3002\uff0e", true},
+		{"\uff61a", true},
+		{"a_b", true},
+		{"-a", true},
+		{"a\u2a74b", true},
+		{"a\u206bb", true},
+		{"a\u0378b", true},
+		{"a\u00e9b", false},
+	} {
+		if output := hasInvalidChars(test.s); output != test.expected {
+			t.Errorf("%q | Output %t not equal to expected %t", test.s, output, test.expected)
+		}
+	}
+}
+
+func TestScriptOf(t *testing.T) {
+	for r, expected := range map[rune]string{
+		'a':      "Latin",
+		'7':      "Common",
+		'-':      "Common",
+		'\u00e9': "Latin",
+		'а':      "Cyrillic",
+		'α':      "Greek",
+		'例':      "Han",
+		'あ':      "Hiragana",
+		'ア':      "Katakana",
+		'\u0301': "Inherited",
+		'\u13a0': "Cherokee",
+		'\u0378': "Unknown",
+	} {
+		if output := scriptOf(r); output != expected {
+			t.Errorf("%q | Output %q not equal to expected %q", r, output, expected)
+		}
+	}
+	for i, script := range scripts {
+		if unicode.Scripts[script.name] != script.table {
+			t.Errorf("scripts[%d] | Table for %q not equal to unicode.Scripts table", i, script.name)
+		}
+	}
+	if len(scripts) != len(unicode.Scripts) {
+		t.Errorf("Expected %d scripts. Got %d.", len(unicode.Scripts), len(scripts))
+	}
+}